@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeString  = "string"
+	TypeInt     = "int"
+	TypeFloat   = "float"
+	TypeDecimal = "decimal"
+	TypeBool    = "bool"
+	TypeDate    = "date"
+)
+
+// ValidateType reports whether targetType is supported by Coerce.
+func ValidateType(targetType string) error {
+	switch targetType {
+	case "", TypeString, TypeInt, TypeFloat, TypeDecimal, TypeBool, TypeDate:
+		return nil
+	default:
+		return fmt.Errorf("unknown type: %s", targetType)
+	}
+}
+
+// Coerce converts value to targetType. nil stays nil whatever the type.
+//
+// Integers are returned as int64, floats as float64 and dates as time.Time.
+// Decimals are returned as strings so that no precision is lost; for them
+// layout optionally holds the number of decimal places. For dates layout is
+// the Go time layout used to parse strings; without it RFC 3339,
+// "2006-01-02 15:04:05" and "2006-01-02" are tried in order.
+func Coerce(value any, targetType string, layout string) (any, error) {
+	if value == nil || targetType == "" {
+		return value, nil
+	}
+
+	switch targetType {
+	case TypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case time.Time:
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			return v.Format(layout), nil
+		default:
+			return fmt.Sprintf("%v", v), nil
+		}
+	case TypeInt:
+		return coerceInt(value)
+	case TypeFloat:
+		return coerceFloat(value)
+	case TypeDecimal:
+		return coerceDecimal(value, layout)
+	case TypeBool:
+		return coerceBool(value)
+	case TypeDate:
+		return coerceDate(value, layout)
+	default:
+		return nil, fmt.Errorf("unknown type: %s", targetType)
+	}
+}
+
+func coerceInt(value any) (any, error) {
+	if i, ok := toInt64(value); ok {
+		return i, nil
+	}
+	switch v := value.(type) {
+	case float32, float64:
+		f, _ := ToFloat(v)
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("cannot convert %v to int without losing precision", v)
+		}
+		return int64(f), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f != math.Trunc(f) {
+			return nil, fmt.Errorf("cannot convert '%s' to int", v)
+		}
+		return int64(f), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to int", value)
+	}
+}
+
+func coerceFloat(value any) (any, error) {
+	if f, ok := ToFloat(value); ok {
+		return f, nil
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert '%s' to float", v)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to float", value)
+	}
+}
+
+func coerceDecimal(value any, layout string) (any, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case float32, float64:
+		f, _ := ToFloat(v)
+		// Use the shortest representation of the float rather than its exact binary value
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	default:
+		i, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %T to decimal", value)
+		}
+		s = strconv.FormatInt(i, 10)
+	}
+
+	var r big.Rat
+	if _, ok := r.SetString(s); !ok {
+		return nil, fmt.Errorf("cannot convert '%s' to decimal", s)
+	}
+	if layout == "" {
+		return s, nil
+	}
+
+	scale, err := strconv.Atoi(layout)
+	if err != nil || scale < 0 {
+		return nil, fmt.Errorf("invalid decimal scale '%s'", layout)
+	}
+	return r.FloatString(scale), nil
+}
+
+func coerceBool(value any) (any, error) {
+	if f, ok := ToFloat(value); ok {
+		return f != 0, nil
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "t", "yes", "y", "1":
+			return true, nil
+		case "false", "f", "no", "n", "0", "":
+			return false, nil
+		}
+		return nil, fmt.Errorf("cannot convert '%s' to bool", v)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to bool", value)
+	}
+}
+
+func coerceDate(value any, layout string) (any, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		layouts := []string{layout}
+		if layout == "" {
+			layouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+		}
+		for _, l := range layouts {
+			if t, err := time.Parse(l, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot parse date '%s'", v)
+	default:
+		// Numbers are read as Unix timestamps in seconds
+		if i, ok := toInt64(value); ok {
+			return time.Unix(i, 0).UTC(), nil
+		}
+		if f, ok := ToFloat(value); ok {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to date", value)
+	}
+}
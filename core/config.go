@@ -0,0 +1,87 @@
+package core
+
+import "fmt"
+
+// ConfigString returns the string value of key, or def when the key is absent.
+func ConfigString(config map[string]any, key string, def string) (string, error) {
+	raw, ok := config[key]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("'%s' must be a string, got %T", key, raw)
+	}
+	return value, nil
+}
+
+// ConfigInt returns the integer value of key, or def when the key is absent.
+func ConfigInt(config map[string]any, key string, def int) (int, error) {
+	raw, ok := config[key]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	switch v := raw.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("'%s' must be a number, got %T", key, raw)
+	}
+}
+
+// ConfigBool returns the boolean value of key, or def when the key is absent.
+func ConfigBool(config map[string]any, key string, def bool) (bool, error) {
+	raw, ok := config[key]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	value, ok := raw.(bool)
+	if !ok {
+		return false, fmt.Errorf("'%s' must be a boolean, got %T", key, raw)
+	}
+	return value, nil
+}
+
+// ConfigStringSlice returns the value of key as a list of strings.
+// A single string is accepted as a list with one element.
+func ConfigStringSlice(config map[string]any, key string) ([]string, error) {
+	raw, ok := config[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must contain only strings, got %T", key, item)
+			}
+			values[i] = s
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a list of strings, got %T", key, raw)
+	}
+}
+
+// ConfigList returns the value of key as a list, or nil when the key is absent.
+func ConfigList(config map[string]any, key string) ([]any, error) {
+	raw, ok := config[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	value, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'%s' must be a list, got %T", key, raw)
+	}
+	return value, nil
+}
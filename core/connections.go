@@ -0,0 +1,94 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConnectionConfig describes a named database connection shared by the steps of a pipeline.
+type ConnectionConfig struct {
+	Name        string
+	Driver      string
+	DSN         string
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+}
+
+// Connections lazily opens and pools the named connections of a pipeline.
+type Connections struct {
+	mu      sync.Mutex
+	configs map[string]ConnectionConfig
+	dbs     map[string]*sql.DB
+}
+
+func NewConnections(configs []ConnectionConfig) *Connections {
+	c := &Connections{
+		configs: make(map[string]ConnectionConfig),
+		dbs:     make(map[string]*sql.DB),
+	}
+	for _, config := range configs {
+		c.configs[config.Name] = config
+	}
+	return c
+}
+
+// Lookup returns the definition of the named connection.
+func (c *Connections) Lookup(name string) (ConnectionConfig, bool) {
+	if c == nil {
+		return ConnectionConfig{}, false
+	}
+	config, ok := c.configs[name]
+	return config, ok
+}
+
+// DB returns the pool of the named connection, opening it on first use.
+func (c *Connections) DB(name string) (*sql.DB, ConnectionConfig, error) {
+	config, ok := c.Lookup(name)
+	if !ok {
+		return nil, config, fmt.Errorf("unknown connection: %s", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if db, ok := c.dbs[name]; ok {
+		return db, config, nil
+	}
+
+	db, err := sql.Open(config.Driver, config.DSN)
+	if err != nil {
+		return nil, config, fmt.Errorf("open connection %s: %w", name, err)
+	}
+	if config.MaxOpen > 0 {
+		db.SetMaxOpenConns(config.MaxOpen)
+	}
+	if config.MaxIdle > 0 {
+		db.SetMaxIdleConns(config.MaxIdle)
+	}
+	if config.MaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.MaxLifetime)
+	}
+	c.dbs[name] = db
+	return db, config, nil
+}
+
+// Close closes every open pool. Connections are reopened on the next use.
+func (c *Connections) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for name, db := range c.dbs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close connection %s: %w", name, err))
+		}
+		delete(c.dbs, name)
+	}
+	return errors.Join(errs...)
+}
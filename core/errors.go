@@ -26,3 +26,16 @@ func (e *InterpolateError) Error() string {
 func ErrInterpolate(key string, value any) error {
 	return &InterpolateError{Key: key, Value: value}
 }
+
+// PluginError is a failure reported by a plugin. Retryable errors may succeed
+// when the step runs again.
+type PluginError struct {
+	Step      string
+	Code      string
+	Message   string
+	Retryable bool
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("plugin step '%s' failed with %s: %s", e.Step, e.Code, e.Message)
+}
@@ -11,4 +11,6 @@ type ChangeEventType string
 const (
 	ChangeEventTypeStart ChangeEventType = "start"
 	ChangeEventTypeEnd   ChangeEventType = "end"
+	ChangeEventTypeSkip  ChangeEventType = "skip"
+	ChangeEventTypeError ChangeEventType = "error"
 )
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// Expression is a script compiled once and evaluated many times, e.g. once per record.
+type Expression struct {
+	Raw     string
+	program *goja.Program
+}
+
+func CompileExpression(raw string) (*Expression, error) {
+	program, err := goja.Compile("", raw, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %w", raw, err)
+	}
+	return &Expression{Raw: raw, program: program}, nil
+}
+
+// Evaluator evaluates expressions on a single runtime holding the pipeline context,
+// so steps working on record lists don't create a runtime per record.
+type Evaluator struct {
+	runtime *goja.Runtime
+}
+
+func NewEvaluator(state *PipelineState) (*Evaluator, error) {
+	runtime, err := newRuntime(state)
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{runtime: runtime}, nil
+}
+
+// Runtime exposes the underlying runtime to steps that need more than expressions, such as scripts.
+func (e *Evaluator) Runtime() *goja.Runtime {
+	return e.runtime
+}
+
+// Set binds a variable, such as the current record, for the next evaluations.
+func (e *Evaluator) Set(name string, value any) error {
+	return e.runtime.Set(name, value)
+}
+
+func (e *Evaluator) Eval(expr *Expression) (any, error) {
+	result, err := e.runtime.RunProgram(expr.program)
+	if err != nil {
+		return nil, err
+	}
+	return result.Export(), nil
+}
+
+func (e *Evaluator) EvalBool(expr *Expression) (bool, error) {
+	result, err := e.runtime.RunProgram(expr.program)
+	if err != nil {
+		return false, err
+	}
+	return result.ToBoolean(), nil
+}
+
+// newRuntime creates a runtime exposing the step results as ctx and the
+// persisted values as state.
+func newRuntime(state *PipelineState) (*goja.Runtime, error) {
+	runtime := goja.New()
+	ctx := make(map[string]any)
+	var watermarks map[string]any
+
+	if state != nil {
+		state.mu.RLock()
+		for stepName, outputs := range state.Results {
+			ctx[stepName] = ContextValue(outputs)
+		}
+		state.mu.RUnlock()
+		watermarks = state.Watermarks.Values()
+	}
+
+	if err := runtime.Set("ctx", ctx); err != nil {
+		return nil, err
+	}
+	if err := runtime.Set("state", map[string]any{"watermark": watermarks}); err != nil {
+		return nil, err
+	}
+	return runtime, nil
+}
+
+// ContextValue returns how the outputs of a step appear in ctx: the default
+// output alone, or an object holding the named outputs and the default output
+// as `default`. The fields of an object default output are merged into it too;
+// named outputs take precedence over fields of the same name.
+func ContextValue(outputs map[string]*Data) any {
+	def, hasDefault := outputs["default"]
+	if hasDefault && len(outputs) == 1 {
+		return def.Value
+	}
+
+	values := make(map[string]any, len(outputs))
+	if hasDefault {
+		if fields, ok := def.Value.(map[string]any); ok {
+			for key, value := range fields {
+				values[key] = value
+			}
+		}
+		values["default"] = def.Value
+	}
+	for name, data := range outputs {
+		if name != "default" {
+			values[name] = data.Value
+		}
+	}
+	return values
+}
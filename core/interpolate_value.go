@@ -1,14 +1,31 @@
 package core
 
-import "github.com/dop251/goja"
+import "fmt"
 
 // InterpolateValue is a generic type for values that support interpolation
 type InterpolateValue[T any] struct {
 	Raw        any
-	TargetType string // Optional, can be used to specify the type of the value
+	TargetType string // Optional, the resolved value is converted to this type (see Coerce)
+	Layout     string // Optional, date layout or decimal scale used by the conversion
 }
 
 func (iv *InterpolateValue[T]) Resolve(state *PipelineState) (T, error) {
+	value, err := iv.resolve(state)
+	if err != nil || iv.TargetType == "" {
+		return value, err
+	}
+
+	coerced, err := Coerce(any(value), iv.TargetType, iv.Layout)
+	if err != nil {
+		return value, err
+	}
+	if v, ok := coerced.(T); ok {
+		return v, nil
+	}
+	return value, fmt.Errorf("cannot store %s value in %T", iv.TargetType, value)
+}
+
+func (iv *InterpolateValue[T]) resolve(state *PipelineState) (T, error) {
 	if v, ok := iv.Raw.(T); ok {
 		// Ensure that if T is string, we don't return here (we want to interpolate strings)
 		_, isString := any(v).(string)
@@ -17,28 +34,8 @@ func (iv *InterpolateValue[T]) Resolve(state *PipelineState) (T, error) {
 		}
 	}
 
-	runtime := goja.New()
-	ctx := make(map[string]any)
-	state.mu.RLock()
-
-	for stepName, outputs := range state.Results {
-		for outName, data := range outputs {
-			if outName == "default" {
-				ctx[stepName] = data.Value
-			} else {
-				// ctx[stepName+"."+outName] = data.Value
-				if ctx[stepName] == nil {
-					ctx[stepName] = make(map[string]any)
-				}
-				stepCtx, _ := ctx[stepName].(map[string]any)
-				stepCtx[outName] = data.Value
-			}
-		}
-	}
-	state.mu.RUnlock()
-
 	var t T
-	err := runtime.Set("ctx", ctx)
+	runtime, err := newRuntime(state)
 	if err != nil {
 		return t, err
 	}
@@ -50,7 +47,7 @@ func (iv *InterpolateValue[T]) Resolve(state *PipelineState) (T, error) {
 
 	switch any(t).(type) {
 	case int:
-		return any(result.ToInteger()).(T), nil
+		return any(int(result.ToInteger())).(T), nil
 	case float64:
 		return any(result.ToFloat()).(T), nil
 	case string:
@@ -58,10 +55,18 @@ func (iv *InterpolateValue[T]) Resolve(state *PipelineState) (T, error) {
 	case bool:
 		return any(result.ToBoolean()).(T), nil
 	default:
-		return any(result.Export()).(T), nil
+		if v, ok := result.Export().(T); ok {
+			return v, nil
+		}
+		// Fall back to goja's conversion, e.g. []map[string]any into []any
+		if err := runtime.ExportTo(result, &t); err != nil {
+			return t, err
+		}
+		return t, nil
 	}
 }
 
+// InterpolateFromType creates a value converted to targetType once resolved.
 func InterpolateFromType(raw any, targetType string) InterpolateValue[any] {
 	return InterpolateValue[any]{Raw: raw, TargetType: targetType}
 }
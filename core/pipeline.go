@@ -7,9 +7,11 @@ import (
 
 // PipelineState holds results of executed steps
 type PipelineState struct {
-	Results map[string]map[string]*Data
-	mu      sync.RWMutex
-	Logger  *slog.Logger
+	Results     map[string]map[string]*Data
+	mu          sync.RWMutex
+	Logger      *slog.Logger
+	Connections *Connections
+	Watermarks  *Watermarks
 }
 
 func (ps *PipelineState) Get(stepName, outputName string) (*Data, bool) {
@@ -23,8 +25,36 @@ func (ps *PipelineState) Get(stepName, outputName string) (*Data, bool) {
 	return data, ok
 }
 
+// Outputs returns every output of a step.
+func (ps *PipelineState) Outputs(stepName string) (map[string]*Data, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	outputs, ok := ps.Results[stepName]
+	return outputs, ok
+}
+
 func (ps *PipelineState) Set(name string, data map[string]*Data) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 	ps.Results[name] = data
 }
+
+// Child returns the state of a sub-pipeline. It starts with a copy of the
+// results of ps, so sub-steps can read the parent ctx without changing it,
+// and shares the logger and connections of ps. Watermarks observed by the
+// sub-pipeline reach ps only when its run succeeds.
+func (ps *PipelineState) Child() *PipelineState {
+	child := &PipelineState{Results: make(map[string]map[string]*Data)}
+	if ps == nil {
+		return child
+	}
+	ps.mu.RLock()
+	for name, outputs := range ps.Results {
+		child.Results[name] = outputs
+	}
+	ps.mu.RUnlock()
+	child.Logger = ps.Logger
+	child.Connections = ps.Connections
+	child.Watermarks = ps.Watermarks.Child()
+	return child
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket holding up to burst requests and refilled
+// with rate requests per second.
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// rateLimiters are shared by every pipeline of the process, so that parallel
+// runs calling the same API share its limits.
+var rateLimiters = struct {
+	sync.Mutex
+	limiters map[string]*RateLimiter
+}{limiters: make(map[string]*RateLimiter)}
+
+// RegisterRateLimiter declares the named rate limiter. A limiter declared
+// again keeps its state and takes the new rate and burst.
+func RegisterRateLimiter(name string, rate float64, burst int) *RateLimiter {
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+	if l, ok := rateLimiters.limiters[name]; ok {
+		l.mu.Lock()
+		l.rate, l.burst = rate, float64(burst)
+		l.tokens = math.Min(l.tokens, l.burst)
+		l.mu.Unlock()
+		return l
+	}
+	l := &RateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	rateLimiters.limiters[name] = l
+	return l
+}
+
+// LookupRateLimiter returns the named rate limiter.
+func LookupRateLimiter(name string) (*RateLimiter, bool) {
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+	l, ok := rateLimiters.limiters[name]
+	return l, ok
+}
+
+// WaitRateLimiter waits for the named rate limiter; an empty name does not wait.
+func WaitRateLimiter(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	l, ok := LookupRateLimiter(name)
+	if !ok {
+		return fmt.Errorf("unknown rate limiter: %s", name)
+	}
+	return l.Wait(ctx)
+}
+
+// Wait blocks until a request is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		var wait time.Duration
+		if now.Before(l.pausedUntil) {
+			wait = l.pausedUntil.Sub(now)
+		} else {
+			l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+			l.last = now
+			if l.tokens >= 1 {
+				l.tokens--
+				l.mu.Unlock()
+				return nil
+			}
+			wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Pause holds every request for d, e.g. after the server asked to retry later.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		// A single request goes through when the pause ends
+		l.pausedUntil = until
+		l.tokens = 1
+		l.last = until
+	}
+}
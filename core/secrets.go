@@ -0,0 +1,34 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveSecret reads the value behind a secret reference.
+// Supported references are "env:NAME" for environment variables and
+// "file:path" for files (trailing newlines are trimmed).
+func ResolveSecret(ref string) (string, error) {
+	kind, value, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid secret reference '%s', expected env:NAME or file:path", ref)
+	}
+
+	switch kind {
+	case "env":
+		secret, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable '%s' is not set", value)
+		}
+		return secret, nil
+	case "file":
+		b, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("unknown secret source '%s' in '%s'", kind, ref)
+	}
+}
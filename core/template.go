@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template evaluates s as a JavaScript template literal, so that
+// "/orders/${ctx.order.id}" inserts the result of the expression. Strings
+// without placeholders are returned unchanged.
+func (e *Evaluator) Template(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	result, err := e.runtime.RunString(templateLiteral(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid template '%s': %w", s, err)
+	}
+	return result.String(), nil
+}
+
+// TemplateValue evaluates the templates of every string in value, walking maps
+// and lists. A string made of a single placeholder, like "${ctx.orders}",
+// keeps the type of the expression result.
+func (e *Evaluator) TemplateValue(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "${") && placeholderEnd(v, 2) == len(v)-1 {
+			result, err := e.runtime.RunString(v[2 : len(v)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid template '%s': %w", v, err)
+			}
+			return result.Export(), nil
+		}
+		return e.Template(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			resolved, err := e.TemplateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := e.TemplateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+// templateLiteral wraps s in backticks, escaping the backslashes and backticks
+// outside of the placeholders.
+func templateLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('`')
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "${") {
+			end := placeholderEnd(s, i+2)
+			if end < 0 {
+				end = len(s) - 1
+			}
+			b.WriteString(s[i : end+1])
+			i = end
+			continue
+		}
+		if s[i] == '\\' || s[i] == '`' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('`')
+	return b.String()
+}
+
+// placeholderEnd returns the index of the brace closing the placeholder whose
+// expression starts at start, or -1.
+func placeholderEnd(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// StateStore persists values between pipeline runs.
+type StateStore interface {
+	Load(prefix string) (map[string]any, error)
+	Save(values map[string]any) error
+	Close() error
+}
+
+// Watermarks holds the committed watermark of each declared name and the
+// candidate values observed during the current run.
+type Watermarks struct {
+	mu        sync.Mutex
+	committed map[string]any
+	pending   map[string]any
+	parent    *Watermarks
+}
+
+func NewWatermarks(committed map[string]any) *Watermarks {
+	if committed == nil {
+		committed = make(map[string]any)
+	}
+	return &Watermarks{committed: committed, pending: make(map[string]any)}
+}
+
+// Child returns the watermarks of a sub-pipeline run. It reads the committed
+// values of w and keeps its own candidates until they are merged into w.
+func (w *Watermarks) Child() *Watermarks {
+	if w == nil {
+		return nil
+	}
+	return &Watermarks{pending: make(map[string]any), parent: w}
+}
+
+// Values returns the committed watermarks, as exposed to expressions through state.watermark.
+func (w *Watermarks) Values() map[string]any {
+	if w == nil {
+		return map[string]any{}
+	}
+	if w.parent != nil {
+		return w.parent.Values()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return maps.Clone(w.committed)
+}
+
+// Observe records value as candidate for name if it is greater than the previous candidate.
+func (w *Watermarks) Observe(name string, value any) {
+	if w == nil || value == nil {
+		return
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.Format(time.RFC3339Nano)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if current, ok := w.pending[name]; !ok || CompareValues(value, current) > 0 {
+		w.pending[name] = value
+	}
+}
+
+// Commit moves the candidates that advance a watermark to the committed set
+// and returns them so they can be persisted.
+func (w *Watermarks) Commit() map[string]any {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	advanced := make(map[string]any)
+	for name, value := range w.pending {
+		if current, ok := w.committed[name]; !ok || CompareValues(value, current) > 0 {
+			w.committed[name] = value
+			advanced[name] = value
+		}
+	}
+	w.pending = make(map[string]any)
+	return advanced
+}
+
+// Merge hands the candidates of a successful sub-pipeline run to its parent.
+func (w *Watermarks) Merge() {
+	if w == nil || w.parent == nil {
+		return
+	}
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string]any)
+	w.mu.Unlock()
+	for name, value := range pending {
+		w.parent.Observe(name, value)
+	}
+}
+
+// Discard drops the candidates observed during a failed run.
+func (w *Watermarks) Discard() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = make(map[string]any)
+}
+
+// RecordList returns value as a list of records when it is one.
+func RecordList(value any) ([]map[string]any, bool) {
+	switch v := value.(type) {
+	case []map[string]any:
+		return v, true
+	case []any:
+		records := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			record, ok := item.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			records = append(records, record)
+		}
+		return records, true
+	default:
+		return nil, false
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"flag"
 	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 
 	"go-etl/core"
 	"go-etl/pipeline"
-	_ "go-etl/steps"
+	"go-etl/steps"
 	"go-etl/web"
 )
 
@@ -16,6 +18,7 @@ func main() {
 	webFlag := flag.Bool("web", false, "Start web server")
 	logFlag := flag.String("log", "debug", "Set log level (debug, info, warn, error)")
 	fileFlag := flag.String("file", "", "Path to pipeline YAML file")
+	pluginsFlag := flag.String("plugins", os.Getenv(steps.PluginsEnv), "Plugin directories, separated like PATH (default $"+steps.PluginsEnv+")")
 
 	flag.Parse()
 
@@ -39,6 +42,11 @@ func main() {
 
 	slog.SetDefault(logger)
 
+	if err := steps.LoadPlugins(filepath.SplitList(*pluginsFlag)); err != nil {
+		logger.Error("Failed to load plugins", "error", err)
+	}
+	defer steps.StopPlugins()
+
 	if fileFlag == nil && !*webFlag {
 		logger.Error("No pipeline file specified. Use -file to provide a YAML file or -web to start the web server.")
 		return
@@ -57,7 +65,8 @@ func main() {
 		return
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	if err = pipeline.Run(ctx, logger); err != nil {
 		logger.Error("Pipeline run failed", "error", err)
 		return
@@ -1,12 +1,65 @@
 package pipeline
 
+import "time"
+
 type PipelineConfig struct {
-	Steps []StepConfig `yaml:"steps"`
+	State       *StateConfig       `yaml:"state"`
+	Connections []ConnectionConfig `yaml:"connections"`
+	// RateLimiters are shared by every pipeline of the process, see core.RegisterRateLimiter.
+	RateLimiters []RateLimiterConfig `yaml:"rate_limiters"`
+	// Params holds the default values of the params of a pipeline called by
+	// another one, available to its steps as ctx.params.<name>.
+	Params map[string]any `yaml:"params"`
+	// Outputs maps the outputs of a called pipeline to `step` or `step:output`.
+	Outputs map[string]string `yaml:"outputs"`
+	Steps   []StepConfig      `yaml:"steps"`
+}
+
+type StateConfig struct {
+	Path string `yaml:"path"`
+}
+
+type ConnectionConfig struct {
+	Name        string        `yaml:"name"`
+	Driver      string        `yaml:"driver"`
+	DSN         string        `yaml:"dsn"`
+	DSNSecret   string        `yaml:"dsn_secret"`
+	MaxOpen     int           `yaml:"max_open"`
+	MaxIdle     int           `yaml:"max_idle"`
+	MaxLifetime time.Duration `yaml:"max_lifetime"`
+}
+
+// RateLimiterConfig allows rate requests per second, with bursts of up to burst requests.
+type RateLimiterConfig struct {
+	Name  string  `yaml:"name"`
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
 }
 
 type StepConfig struct {
-	Name   string                 `yaml:"name"`
-	Type   string                 `yaml:"type"`
-	Inputs []string               `yaml:"inputs"`
-	Config map[string]interface{} `yaml:"config"`
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	Inputs      []string               `yaml:"inputs"`
+	TriggerRule string                 `yaml:"trigger_rule"`
+	Watermark   *WatermarkConfig       `yaml:"watermark"`
+	Config      map[string]interface{} `yaml:"config"`
+}
+
+// Trigger rules decide whether a step runs once its inputs are done.
+const (
+	// TriggerAllSuccess runs the step when every input was produced. It is the default.
+	TriggerAllSuccess = "all_success"
+	// TriggerAnySuccess runs the step when at least one input was produced, e.g. to merge branches.
+	TriggerAnySuccess = "any_success"
+	// TriggerAllDone runs the step once every input step finished, whatever its outcome.
+	TriggerAllDone = "all_done"
+)
+
+// WatermarkConfig tracks the maximum value of a column of the step output.
+// The value is committed to the state store only when the run succeeds.
+type WatermarkConfig struct {
+	Name    string `yaml:"name"`
+	Column  string `yaml:"column"`
+	Output  string `yaml:"output"`
+	Initial any    `yaml:"initial"`
 }
@@ -2,9 +2,13 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
@@ -14,33 +18,77 @@ import (
 )
 
 type Pipeline struct {
-	steps    map[string]core.Step
-	triggers map[string]core.Trigger
-	inputs   map[string][]string
-	state    *core.PipelineState
+	steps       map[string]core.Step
+	triggers    map[string]core.Trigger
+	inputs      map[string][]string
+	rules       map[string]string
+	state       *core.PipelineState
+	connections *core.Connections
+	watermarks  map[string]WatermarkConfig
+	statePath   string
+	// file is the absolute path of the pipeline file, if loaded from one
+	file string
+	// sub is set on runs made by a step, such as foreach items
+	sub      bool
 	OnChange func(event core.ChangeEvent)
 }
 
 func LoadPipelineFromFile(filePath string) (*Pipeline, error) {
+	config, err := ReadConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := LoadPipeline(config)
+	if err != nil {
+		return nil, err
+	}
+	if p.file, err = filepath.Abs(filePath); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// callChainKey holds in the context the files of the pipelines being run,
+// the innermost last.
+type callChainKey struct{}
+
+// CallChain returns the files of the pipelines being run in ctx, the innermost last.
+func CallChain(ctx context.Context) []string {
+	chain, _ := ctx.Value(callChainKey{}).([]string)
+	return chain
+}
+
+// WithCall returns ctx with file appended to its call chain.
+func WithCall(ctx context.Context, file string) context.Context {
+	return context.WithValue(ctx, callChainKey{}, append(slices.Clip(CallChain(ctx)), file))
+}
+
+// ReadConfig decodes a pipeline file.
+func ReadConfig(filePath string) (PipelineConfig, error) {
+	var config PipelineConfig
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open pipeline file: %w", err)
+		return config, fmt.Errorf("failed to open pipeline file: %w", err)
 	}
 	defer file.Close()
 
-	var config PipelineConfig
 	dec := yaml.NewDecoder(file)
 	if err := dec.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode pipeline config: %w", err)
+		return config, fmt.Errorf("failed to decode pipeline config: %w", err)
 	}
-
-	return LoadPipeline(config)
+	return config, nil
 }
 
 func LoadPipeline(config PipelineConfig) (*Pipeline, error) {
 	stepsMap := make(map[string]core.Step)
 	triggersMap := make(map[string]core.Trigger)
 	inputs := make(map[string][]string)
+	rules := make(map[string]string)
+
+	// Limiters are registered first, so that step factories can look them up
+	if err := loadRateLimiters(config.RateLimiters); err != nil {
+		return nil, err
+	}
 
 	for _, sc := range config.Steps {
 		factoryType, factory, ok := GetFactory(sc.Type)
@@ -60,27 +108,165 @@ func LoadPipeline(config PipelineConfig) (*Pipeline, error) {
 		slog.Info("Load", slog.String("step", sc.Name), slog.String("type", factoryType))
 
 		inputs[sc.Name] = sc.Inputs
+
+		switch sc.TriggerRule {
+		case "", TriggerAllSuccess, TriggerAnySuccess, TriggerAllDone:
+			rules[sc.Name] = sc.TriggerRule
+		default:
+			return nil, fmt.Errorf("step %s: unknown trigger rule: %s", sc.Name, sc.TriggerRule)
+		}
+	}
+
+	if err := checkInputs(stepsMap, triggersMap, inputs); err != nil {
+		return nil, err
+	}
+
+	for name, ref := range config.Outputs {
+		stepName, _, _ := strings.Cut(ref, ":")
+		if _, ok := stepsMap[stepName]; !ok {
+			return nil, fmt.Errorf("output %s: unknown step %s", name, stepName)
+		}
+	}
+
+	connections, err := loadConnections(config.Connections)
+	if err != nil {
+		return nil, err
+	}
+
+	watermarks, err := loadWatermarks(config.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	var statePath string
+	if config.State != nil {
+		statePath = config.State.Path
+	}
+
+	return &Pipeline{
+		steps:       stepsMap,
+		triggers:    triggersMap,
+		inputs:      inputs,
+		rules:       rules,
+		connections: connections,
+		watermarks:  watermarks,
+		statePath:   statePath,
+	}, nil
+}
+
+func loadConnections(configs []ConnectionConfig) (*core.Connections, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	connections := make([]core.ConnectionConfig, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, cc := range configs {
+		if cc.Name == "" {
+			return nil, fmt.Errorf("connection without name")
+		}
+		if seen[cc.Name] {
+			return nil, fmt.Errorf("duplicate connection: %s", cc.Name)
+		}
+		seen[cc.Name] = true
+
+		if cc.Driver == "" {
+			return nil, fmt.Errorf("connection %s: missing driver", cc.Name)
+		}
+
+		dsn := cc.DSN
+		if cc.DSNSecret != "" {
+			secret, err := core.ResolveSecret(cc.DSNSecret)
+			if err != nil {
+				return nil, fmt.Errorf("connection %s: %w", cc.Name, err)
+			}
+			dsn = secret
+		}
+
+		connections = append(connections, core.ConnectionConfig{
+			Name:        cc.Name,
+			Driver:      cc.Driver,
+			DSN:         dsn,
+			MaxOpen:     cc.MaxOpen,
+			MaxIdle:     cc.MaxIdle,
+			MaxLifetime: cc.MaxLifetime,
+		})
 	}
 
-	return &Pipeline{steps: stepsMap, triggers: triggersMap, inputs: inputs}, nil
+	return core.NewConnections(connections), nil
+}
+
+func loadRateLimiters(configs []RateLimiterConfig) error {
+	seen := make(map[string]bool)
+	for _, rc := range configs {
+		if rc.Name == "" {
+			return fmt.Errorf("rate limiter without name")
+		}
+		if seen[rc.Name] {
+			return fmt.Errorf("duplicate rate limiter: %s", rc.Name)
+		}
+		seen[rc.Name] = true
+
+		if rc.Rate <= 0 {
+			return fmt.Errorf("rate limiter %s: rate must be positive", rc.Name)
+		}
+		burst := rc.Burst
+		if burst <= 0 {
+			burst = max(1, int(rc.Rate))
+		}
+		core.RegisterRateLimiter(rc.Name, rc.Rate, burst)
+	}
+	return nil
 }
 
 func (p *Pipeline) Run(ctx context.Context, logger *slog.Logger) error {
 	if p.state == nil {
 		p.state = &core.PipelineState{Results: make(map[string]map[string]*core.Data), Logger: logger}
 	}
+	if p.state.Logger == nil {
+		p.state.Logger = logger
+	}
+	if p.state.Connections == nil {
+		p.state.Connections = p.connections
+	}
 
-	core.StartWebServer()
+	// The pools of a sub-pipeline are shared by its runs and closed by its owner
+	if !p.sub {
+		defer p.Close()
+		defer func() {
+			for _, cleanup := range runCleanups {
+				cleanup()
+			}
+		}()
+	}
+
+	// Sub-pipeline runs rely on the server of the top-level run
+	if !p.sub {
+		core.StartWebServer()
+	}
+	// Steps resolve relative files, such as called pipelines, from the pipeline file
+	if !p.sub && p.file != "" {
+		ctx = WithCall(ctx, p.file)
+	}
 
 	if len(p.triggers) > 0 {
 		logger.Info("Found", slog.Int("triggers", len(p.triggers)))
-		p.RunFromTriggers()
+		p.RunFromTriggers(ctx)
 		return nil
 	}
 
+	stateStore, err := p.openWatermarks()
+	if err != nil {
+		return err
+	}
+	if stateStore != nil {
+		defer stateStore.Close()
+	}
+
 	done := make(map[string]chan struct{})
 	var wg sync.WaitGroup
 	mu := sync.Mutex{}
+	var errs []error
 
 	// Create done channels
 	for _, step := range p.steps {
@@ -89,7 +275,11 @@ func (p *Pipeline) Run(ctx context.Context, logger *slog.Logger) error {
 
 	exec := func(step core.Step) {
 		defer wg.Done()
-		// Wait for all inputs
+		defer close(done[step.Name()])
+
+		// Wait for all inputs. Failed and skipped steps produce no output, so
+		// their dependents are skipped in turn unless their trigger rule allows it.
+		produced := 0
 		for _, input := range p.inputs[step.Name()] {
 			parts := strings.Split(input, ":")
 			stepName := parts[0]
@@ -97,14 +287,21 @@ func (p *Pipeline) Run(ctx context.Context, logger *slog.Logger) error {
 			if len(parts) == 2 {
 				outputName = parts[1]
 			}
-			<-done[stepName]
+			// Results of triggers are already in the state
+			if ch, ok := done[stepName]; ok {
+				<-ch
+			}
+			if _, ok := p.state.Get(stepName, outputName); ok {
+				produced++
+			}
+		}
 
-			mu.Lock()
-			if _, ok := p.state.Get(stepName, outputName); !ok {
-				mu.Unlock()
-				return
+		if !shouldRun(p.rules[step.Name()], len(p.inputs[step.Name()]), produced) {
+			logger.Debug("Skipping step", slog.String("step", step.Name()))
+			if p.OnChange != nil {
+				p.OnChange(core.ChangeEvent{Type: core.ChangeEventTypeSkip, StepName: step.Name()})
 			}
-			mu.Unlock()
+			return
 		}
 		logger.Debug("Running step", slog.String("step", step.Name()))
 
@@ -115,10 +312,16 @@ func (p *Pipeline) Run(ctx context.Context, logger *slog.Logger) error {
 		outputs, err := step.Run(ctx, p.state)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Step %s failed: %v\n", step.Name(), err)
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("step %s: %w", step.Name(), err))
+			mu.Unlock()
+			if p.OnChange != nil {
+				p.OnChange(core.ChangeEvent{Type: core.ChangeEventTypeError, StepName: step.Name()})
+			}
 			return
 		}
+		p.observeWatermark(step.Name(), outputs)
 		p.state.Set(step.Name(), outputs)
-		close(done[step.Name()])
 		logger.Debug("Step completed", slog.String("step", step.Name()), slog.Any("output", outputs))
 		if p.OnChange != nil {
 			p.OnChange(core.ChangeEvent{Type: core.ChangeEventTypeEnd, StepName: step.Name(), Data: outputs})
@@ -131,6 +334,15 @@ func (p *Pipeline) Run(ctx context.Context, logger *slog.Logger) error {
 	}
 
 	wg.Wait()
+
+	if len(errs) > 0 {
+		p.state.Watermarks.Discard()
+		return errors.Join(errs...)
+	}
+	if stateStore != nil {
+		return p.commitWatermarks(stateStore)
+	}
+	p.state.Watermarks.Merge()
 	return nil
 }
 
@@ -138,18 +350,45 @@ func (p *Pipeline) SetState(state *core.PipelineState) {
 	p.state = state
 }
 
-func (p *Pipeline) RunFromTriggers() {
+// StepNames returns the names of the steps of the pipeline, triggers excluded.
+func (p *Pipeline) StepNames() []string {
+	return slices.Sorted(maps.Keys(p.steps))
+}
+
+// WithState returns a copy of the pipeline running on state, so that a loaded
+// pipeline can run several times concurrently. The copy runs as a sub-pipeline
+// and does not start the web server.
+func (p *Pipeline) WithState(state *core.PipelineState) *Pipeline {
+	run := *p
+	run.state = state
+	run.sub = true
+	return &run
+}
+
+// Close releases the connection pools owned by the pipeline.
+func (p *Pipeline) Close() error {
+	return p.connections.Close()
+}
+
+// RunFromTriggers starts a pipeline run for each trigger event until ctx is done.
+func (p *Pipeline) RunFromTriggers(ctx context.Context) {
 	// wg := sync.WaitGroup{}
 	// wg.Add(1)
 	for _, trigger := range p.triggers {
 		slog.Info("Trigger", "name", trigger.Name())
 		trigger.SetOnTrigger(func(data map[string]*core.Data) {
 			newP := Pipeline{
-				steps: p.steps,
+				steps:      p.steps,
+				inputs:     p.inputs,
+				rules:      p.rules,
+				watermarks: p.watermarks,
+				statePath:  p.statePath,
+				file:       p.file,
 				state: &core.PipelineState{
 					Results: map[string]map[string]*core.Data{
 						trigger.Name(): data,
 					},
+					Connections: p.connections,
 				},
 			}
 
@@ -160,6 +399,65 @@ func (p *Pipeline) RunFromTriggers() {
 		})
 	}
 	slog.Info("Waiting for triggers")
-	select {}
+	<-ctx.Done()
 	// wg.Wait()
 }
+
+// checkInputs rejects inputs naming no step or trigger, and dependency cycles,
+// which would never be satisfied.
+func checkInputs(steps map[string]core.Step, triggers map[string]core.Trigger, inputs map[string][]string) error {
+	for _, name := range slices.Sorted(maps.Keys(steps)) {
+		for _, input := range inputs[name] {
+			stepName, _, _ := strings.Cut(input, ":")
+			_, isStep := steps[stepName]
+			if _, isTrigger := triggers[stepName]; !isStep && !isTrigger {
+				return fmt.Errorf("step %s: unknown input %s", name, input)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, input := range inputs[name] {
+			stepName, _, _ := strings.Cut(input, ":")
+			if _, ok := steps[stepName]; !ok {
+				continue
+			}
+			if err := visit(stepName, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(steps)) {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shouldRun applies the trigger rule of a step to the number of its inputs that were produced.
+func shouldRun(rule string, inputs, produced int) bool {
+	switch rule {
+	case TriggerAllDone:
+		return true
+	case TriggerAnySuccess:
+		return inputs == 0 || produced > 0
+	default:
+		return produced == inputs
+	}
+}
@@ -8,6 +8,7 @@ type StepFactory func(name string, config map[string]any) (core.Step, error)
 
 var stepRegistry = make(map[string]StepFactory)
 var triggerRegistry = make(map[string]StepFactory)
+var runCleanups []func()
 
 func RegisterStepType(stepType string, factory StepFactory) {
 	stepRegistry[stepType] = factory
@@ -17,6 +18,12 @@ func RegisterTriggerType(stepType string, factory StepFactory) {
 	triggerRegistry[stepType] = factory
 }
 
+// RegisterRunCleanup registers fn to be called when a top-level run ends, for
+// step types keeping resources across the steps of a run.
+func RegisterRunCleanup(fn func()) {
+	runCleanups = append(runCleanups, fn)
+}
+
 // func RegisterTriggerType(triggerType string, factory core.TriggerFactory) {
 // 	stepRegistry[triggerType] = factory
 // }
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"go-etl/core"
+	"go-etl/store"
+)
+
+const watermarkPrefix = "watermark."
+
+// watermarkCommits serializes the commits of concurrent runs, e.g. started by triggers.
+var watermarkCommits sync.Mutex
+
+// openWatermarks loads the committed watermarks from the state store.
+// Sub-pipelines inherit the watermarks of their parent and never commit them.
+func (p *Pipeline) openWatermarks() (core.StateStore, error) {
+	if p.state.Watermarks != nil || (len(p.watermarks) == 0 && p.statePath == "") {
+		return nil, nil
+	}
+
+	path := p.statePath
+	if path == "" {
+		path = store.DefaultPath
+	}
+	stateStore, err := store.OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := stateStore.Load(watermarkPrefix)
+	if err != nil {
+		stateStore.Close()
+		return nil, err
+	}
+	for _, wm := range p.watermarks {
+		if _, ok := values[wm.Name]; !ok && wm.Initial != nil {
+			values[wm.Name] = wm.Initial
+		}
+	}
+
+	p.state.Watermarks = core.NewWatermarks(values)
+	return stateStore, nil
+}
+
+// observeWatermark records the maximum watermark column of the step output.
+func (p *Pipeline) observeWatermark(stepName string, outputs map[string]*core.Data) {
+	wm, ok := p.watermarks[stepName]
+	if !ok {
+		return
+	}
+
+	output := wm.Output
+	if output == "" {
+		output = "default"
+	}
+	data, ok := outputs[output]
+	if !ok {
+		return
+	}
+
+	records, ok := core.RecordList(data.Value)
+	if !ok {
+		p.state.Logger.Warn("Watermark output is not a record list", slog.String("step", stepName), slog.String("output", output))
+		return
+	}
+	for _, record := range records {
+		p.state.Watermarks.Observe(wm.Name, record[wm.Column])
+	}
+}
+
+// commitWatermarks persists the watermarks advanced by a successful run.
+func (p *Pipeline) commitWatermarks(stateStore core.StateStore) error {
+	advanced := p.state.Watermarks.Commit()
+	if len(advanced) == 0 {
+		return nil
+	}
+
+	watermarkCommits.Lock()
+	defer watermarkCommits.Unlock()
+
+	// A concurrent run that started later may have stored a greater value meanwhile
+	stored, err := stateStore.Load(watermarkPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to commit watermarks: %w", err)
+	}
+	values := make(map[string]any, len(advanced))
+	for name, value := range advanced {
+		if current, ok := stored[name]; ok && core.CompareValues(value, current) <= 0 {
+			delete(advanced, name)
+			continue
+		}
+		values[watermarkPrefix+name] = value
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := stateStore.Save(values); err != nil {
+		return fmt.Errorf("failed to commit watermarks: %w", err)
+	}
+	p.state.Logger.Info("Watermarks committed", slog.Any("watermarks", advanced))
+	return nil
+}
+
+func loadWatermarks(steps []StepConfig) (map[string]WatermarkConfig, error) {
+	watermarks := make(map[string]WatermarkConfig)
+	for _, sc := range steps {
+		if sc.Watermark == nil {
+			continue
+		}
+		if sc.Watermark.Name == "" || sc.Watermark.Column == "" {
+			return nil, fmt.Errorf("step %s: watermark requires 'name' and 'column'", sc.Name)
+		}
+		watermarks[sc.Name] = *sc.Watermark
+	}
+	return watermarks, nil
+}
@@ -3,8 +3,6 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"os"
 
 	sdk "go-etl-sdk"
 
@@ -16,34 +14,33 @@ type PluginInput struct {
 	Query      string `json:"query"`
 }
 
-type PluginOutput struct {
-	Value string `json:"value"`
-	Error error  `json:"error"`
-}
-
 func main() {
 	input, err := sdk.ReadInput[PluginInput]()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "read error: %v\n", err)
-		os.Exit(1)
+		sdk.Fail(sdk.NewError("invalid_input", err.Error(), false))
 	}
 
 	db, err := sql.Open("sqlserver", input.Connection)
 	if err != nil {
-		return
+		sdk.Fail(sdk.NewError("invalid_connection", err.Error(), false))
 	}
 	defer db.Close()
 
 	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		// The server may be temporarily unavailable
+		sdk.Fail(sdk.NewError("connection_failed", err.Error(), true))
+	}
+
 	rows, err := db.QueryContext(ctx, input.Query)
 	if err != nil {
-		return
+		sdk.Fail(sdk.NewError("query_failed", err.Error(), false))
 	}
 	defer rows.Close()
 
 	cols, err := rows.Columns()
 	if err != nil {
-		return
+		sdk.Fail(sdk.NewError("query_failed", err.Error(), false))
 	}
 
 	results := []map[string]interface{}{}
@@ -56,7 +53,7 @@ func main() {
 		}
 
 		if err := rows.Scan(ptrs...); err != nil {
-			return
+			sdk.Fail(sdk.NewError("scan_failed", err.Error(), false))
 		}
 
 		rowMap := make(map[string]interface{})
@@ -65,6 +62,10 @@ func main() {
 		}
 		results = append(results, rowMap)
 	}
+	if err := rows.Err(); err != nil {
+		sdk.Fail(sdk.NewError("query_failed", err.Error(), false))
+	}
 
+	sdk.Log("info", "query done", map[string]any{"rows": len(results)})
 	sdk.WriteOutput(results)
 }
@@ -21,14 +21,9 @@ func ReadInput[T any]() (T, error) {
 	return input, nil
 }
 
+// WriteOutput writes the successful result of an exec plugin.
 func WriteOutput[T any](output T) {
-	outputData, err := json.Marshal(output)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "json output error: %v\n", err)
-		os.Exit(1)
-	}
-
-	os.Stdout.Write(outputData)
+	writeResult(Result{OK: true, Outputs: output})
 }
 
 func ReadConfiguration(path string) (Configuration, error) {
@@ -45,16 +40,53 @@ func ReadConfiguration(path string) (Configuration, error) {
 	return config, nil
 }
 
+// Input types of the plugin.json manifest.
+const (
+	TypeText       = "text"
+	TypeNumber     = "number"
+	TypeInteger    = "integer"
+	TypeBoolean    = "boolean"
+	TypeList       = "list"
+	TypeObject     = "object"
+	TypeConnection = "connection" // a named connection, passed as its DSN
+	TypeAny        = "any"
+)
+
 type Configuration struct {
-	Name    string           `json:"name"`
-	Version string           `json:"version"`
-	Inputs  map[string]Input `json:"inputs"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	// Type is the step type of a plugin found in the plugin search path,
+	// by default the name of its directory without the _plugin suffix.
+	Type string `json:"type,omitempty"`
+	// Command is the plugin program, relative to the manifest directory. It
+	// defaults to the name of the directory.
+	Command string `json:"command,omitempty"`
+	// Protocol is ProtocolExec (default) or ProtocolJSONRPC.
+	Protocol string           `json:"protocol,omitempty"`
+	Inputs   map[string]Input `json:"inputs"`
+	// Outputs declares named outputs. The plugin then returns an object
+	// holding a value per output, "default" being the default output.
+	Outputs map[string]Output `json:"outputs,omitempty"`
 }
 
 type Input struct {
-	Type          string `json:"type"`
-	Label         string `json:"label"`
-	Default       any    `json:"default,omitempty"`
-	Interpolation bool   `json:"interpolation,omitempty"`
-	Required      bool   `json:"required,omitempty"`
+	Type          string   `json:"type"`
+	Label         string   `json:"label"`
+	Description   string   `json:"description,omitempty"`
+	Default       any      `json:"default,omitempty"`
+	Interpolation bool     `json:"interpolation,omitempty"`
+	Required      bool     `json:"required,omitempty"`
+	Enum          []any    `json:"enum,omitempty"`
+	Min           *float64 `json:"min,omitempty"` // minimum number, or length of text and lists
+	Max           *float64 `json:"max,omitempty"` // maximum number, or length of text and lists
+	// Secret inputs can be given as a secret reference with the `<name>_secret`
+	// key, e.g. password_secret: env:DB_PASSWORD, and are never logged.
+	Secret bool `json:"secret,omitempty"`
+}
+
+type Output struct {
+	Type        string `json:"type"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
 }
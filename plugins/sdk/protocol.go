@@ -0,0 +1,86 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The persistent plugin protocol exchanges JSON-RPC 2.0 messages over the
+// plugin's stdin and stdout, one message per line. The engine starts with an
+// initialize request, then sends run requests, possibly concurrently, whose
+// params are the step configuration and whose result is the step output. A
+// run is cancelled with a cancel notification. The plugin sends log
+// notifications while it runs. A shutdown request, sent when the plugin is
+// idle or the engine exits, stops the plugin, and so does closing stdin.
+const (
+	ProtocolVersion = 1
+
+	// ProtocolExec runs the plugin once per call with the configuration on
+	// stdin and the output on stdout. It is the default.
+	ProtocolExec = "exec"
+	// ProtocolJSONRPC serves many calls from one plugin process.
+	ProtocolJSONRPC = "jsonrpc"
+
+	MethodInitialize = "initialize"
+	MethodRun        = "run"
+	MethodCancel     = "cancel"
+	MethodLog        = "log"
+	MethodShutdown   = "shutdown"
+
+	CapabilityCancel = "cancel"
+	CapabilityLogs   = "logs"
+)
+
+// JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeCancelled      = -32800
+)
+
+// Message is a JSON-RPC request, notification (without id) or response.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Data holds the plugin error returned by a handler.
+	Data *Error `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+type InitializeParams struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+type CancelParams struct {
+	ID int64 `json:"id"`
+}
+
+// LogParams is a log line of the run request ID. Exec plugins write them on
+// stderr, without ID.
+type LogParams struct {
+	ID      int64          `json:"id,omitempty"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
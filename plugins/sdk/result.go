@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ResultVersion marks the envelope written by the SDK, so that the engine
+// does not mistake plain output of the same shape for it.
+const ResultVersion = 1
+
+// Result is the envelope written on stdout by a plugin using the exec
+// protocol: {"etl_result": 1, "ok": true, "outputs": ...} or
+// {"etl_result": 1, "ok": false, "error": {...}}.
+type Result struct {
+	Version int    `json:"etl_result"`
+	OK      bool   `json:"ok"`
+	Outputs any    `json:"outputs,omitempty"`
+	Error   *Error `json:"error,omitempty"`
+}
+
+// Error is a plugin failure. Retryable errors, such as timeouts, may succeed
+// when the call is made again.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// NewError returns a plugin error with a machine readable code.
+func NewError(code, message string, retryable bool) *Error {
+	return &Error{Code: code, Message: message, Retryable: retryable}
+}
+
+// asError returns err as a plugin error, with the "plugin_error" code when it
+// is not one.
+func asError(err error) *Error {
+	var pluginErr *Error
+	if errors.As(err, &pluginErr) {
+		return pluginErr
+	}
+	return &Error{Code: "plugin_error", Message: err.Error()}
+}
+
+// Fail writes err as the result of an exec plugin and exits. Persistent
+// plugins return the error from their handler instead.
+func Fail(err error) {
+	writeResult(Result{Error: asError(err)})
+	os.Exit(1)
+}
+
+// Log writes a log line on stderr, which the engine writes to the pipeline
+// log with the step name. Levels are debug, info, warn and error. Persistent
+// plugins use Call.Log to attach the line to the step of a call.
+func Log(level, message string, attrs map[string]any) {
+	b, err := json.Marshal(LogParams{Level: level, Message: message, Attrs: attrs})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", level, message)
+		return
+	}
+	os.Stderr.Write(append(b, '\n'))
+}
+
+func writeResult(result Result) {
+	result.Version = ResultVersion
+	outputData, err := json.Marshal(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "json output error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(outputData)
+}
@@ -0,0 +1,178 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Handler runs one call of a persistent plugin. Calls may run concurrently;
+// ctx is cancelled when the engine cancels the call.
+type Handler func(ctx context.Context, call *Call) (any, error)
+
+// Call is a run request.
+type Call struct {
+	ID     int64
+	Params json.RawMessage
+	conn   *conn
+}
+
+// Decode decodes the step configuration into v.
+func (c *Call) Decode(v any) error {
+	if err := json.Unmarshal(c.Params, v); err != nil {
+		return &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// Log sends a log line to the engine, attached to the step of the call.
+// Levels are debug, info, warn and error.
+func (c *Call) Log(level, message string, attrs map[string]any) {
+	c.conn.notify(MethodLog, LogParams{ID: c.ID, Level: level, Message: message, Attrs: attrs})
+}
+
+// Serve serves run requests on stdin and stdout until stdin is closed or the
+// engine asks to shut down. The plugin.json manifest must declare
+// "protocol": "jsonrpc".
+func Serve(name, version string, handler Handler) error {
+	return serve(os.Stdin, os.Stdout, name, version, handler)
+}
+
+type conn struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (c *conn) send(msg Message) {
+	msg.JSONRPC = "2.0"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(msg); err != nil {
+		fmt.Fprintf(os.Stderr, "write error: %v\n", err)
+	}
+}
+
+func (c *conn) notify(method string, params any) {
+	b, _ := json.Marshal(params)
+	c.send(Message{Method: method, Params: b})
+}
+
+func (c *conn) reply(id *int64, result any, err error) {
+	if id == nil {
+		return
+	}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			pluginErr := asError(err)
+			rpcErr = &RPCError{Code: CodeInternalError, Message: pluginErr.Message, Data: pluginErr}
+		}
+		c.send(Message{ID: id, Error: rpcErr})
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		c.send(Message{ID: id, Error: &RPCError{Code: CodeInternalError, Message: fmt.Sprintf("json output error: %v", err)}})
+		return
+	}
+	c.send(Message{ID: id, Result: b})
+}
+
+func serve(r io.Reader, w io.Writer, name, version string, handler Handler) error {
+	c := &conn{enc: json.NewEncoder(w)}
+	reader := bufio.NewReader(r)
+
+	var mu sync.Mutex
+	running := make(map[int64]context.CancelFunc)
+	var wg sync.WaitGroup
+	defer func() {
+		mu.Lock()
+		for _, cancel := range running {
+			cancel()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}()
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read error: %w", err)
+		}
+
+		var msg Message
+		if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+			c.send(Message{Error: &RPCError{Code: CodeParseError, Message: jsonErr.Error()}})
+			continue
+		}
+
+		switch msg.Method {
+		case MethodInitialize:
+			c.reply(msg.ID, InitializeResult{
+				ProtocolVersion: ProtocolVersion,
+				Name:            name,
+				Version:         version,
+				Capabilities:    []string{CapabilityCancel, CapabilityLogs},
+			}, nil)
+		case MethodRun:
+			if msg.ID == nil {
+				continue
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			id := *msg.ID
+			mu.Lock()
+			running[id] = cancel
+			mu.Unlock()
+
+			wg.Add(1)
+			go func(msg Message) {
+				defer wg.Done()
+				result, err := run(ctx, handler, &Call{ID: id, Params: msg.Params, conn: c})
+				if ctx.Err() != nil && err != nil {
+					err = &RPCError{Code: CodeCancelled, Message: "call cancelled"}
+				}
+				mu.Lock()
+				delete(running, id)
+				mu.Unlock()
+				cancel()
+				c.reply(msg.ID, result, err)
+			}(msg)
+		case MethodCancel:
+			var params CancelParams
+			if err := json.Unmarshal(msg.Params, &params); err == nil {
+				mu.Lock()
+				if cancel, ok := running[params.ID]; ok {
+					cancel()
+				}
+				mu.Unlock()
+			}
+		case MethodShutdown:
+			c.reply(msg.ID, nil, nil)
+			return nil
+		default:
+			c.reply(msg.ID, nil, &RPCError{Code: CodeMethodNotFound, Message: "unknown method: " + msg.Method})
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// run calls handler, turning a panic into an error so that the plugin keeps serving.
+func run(ctx context.Context, handler Handler, call *Call) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panic: %v", r)
+		}
+	}()
+	return handler(ctx, call)
+}
@@ -0,0 +1,307 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"math"
+)
+
+const (
+	AggregateCount         = "count"
+	AggregateSum           = "sum"
+	AggregateMin           = "min"
+	AggregateMax           = "max"
+	AggregateAvg           = "avg"
+	AggregateFirst         = "first"
+	AggregateLast          = "last"
+	AggregateCollect       = "collect"
+	AggregateCountDistinct = "count_distinct"
+)
+
+type groupKey struct {
+	name  string
+	value recordValue
+}
+
+type aggregateDef struct {
+	name     string
+	op       string
+	value    recordValue
+	hasValue bool
+}
+
+// accumulator holds the running value of one aggregate for one group.
+type accumulator struct {
+	count    int64
+	sum      float64
+	integral bool // sum only saw whole numbers
+	value    any
+	seen     bool
+	values   []any
+	distinct map[string]bool
+}
+
+// AggregateStep groups a record list by key values and computes aggregates for each group.
+type AggregateStep struct {
+	name       string
+	list       core.InterpolateValue[[]any]
+	groupBy    []groupKey
+	aggregates []aggregateDef
+}
+
+func (a *AggregateStep) Name() string { return a.name }
+
+func (a *AggregateStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := a.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", a.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		keys []any
+		accs []*accumulator
+	}
+	groups := make(map[string]*group)
+	var order []string
+
+	for i, record := range list {
+		keys := make([]any, len(a.groupBy))
+		for k, g := range a.groupBy {
+			if keys[k], err = g.value.get(ev, record, i); err != nil {
+				return nil, fmt.Errorf("group key '%s' failed on record %d: %w", g.name, i, err)
+			}
+		}
+
+		id := keyString(keys...)
+		g, ok := groups[id]
+		if !ok {
+			g = &group{keys: keys, accs: newAccumulators(len(a.aggregates))}
+			groups[id] = g
+			order = append(order, id)
+		}
+
+		for k, def := range a.aggregates {
+			var value any
+			if def.hasValue {
+				if value, err = def.value.get(ev, record, i); err != nil {
+					return nil, fmt.Errorf("aggregate '%s' failed on record %d: %w", def.name, i, err)
+				}
+			}
+			if err := g.accs[k].add(def, value); err != nil {
+				return nil, fmt.Errorf("aggregate '%s' failed on record %d: %w", def.name, i, err)
+			}
+		}
+	}
+
+	// Without group keys an empty list still yields one summary record
+	if len(a.groupBy) == 0 && len(order) == 0 {
+		groups[""] = &group{accs: newAccumulators(len(a.aggregates))}
+		order = append(order, "")
+	}
+
+	results := make([]any, 0, len(order))
+	for _, id := range order {
+		g := groups[id]
+		out := make(map[string]any, len(a.groupBy)+len(a.aggregates))
+		for k, key := range a.groupBy {
+			out[key.name] = g.keys[k]
+		}
+		for k, def := range a.aggregates {
+			out[def.name] = g.accs[k].result(def.op)
+		}
+		results = append(results, out)
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+func newAccumulators(n int) []*accumulator {
+	accs := make([]*accumulator, n)
+	for i := range accs {
+		accs[i] = &accumulator{integral: true, distinct: make(map[string]bool)}
+	}
+	return accs
+}
+
+func (acc *accumulator) add(def aggregateDef, value any) error {
+	switch def.op {
+	case AggregateCount:
+		if !def.hasValue || value != nil {
+			acc.count++
+		}
+	case AggregateSum, AggregateAvg:
+		if value == nil {
+			return nil
+		}
+		f, ok := core.ToFloat(value)
+		if !ok {
+			return fmt.Errorf("value %v is not a number", value)
+		}
+		if f != math.Trunc(f) {
+			acc.integral = false
+		}
+		acc.sum += f
+		acc.count++
+	case AggregateMin, AggregateMax:
+		if value == nil {
+			return nil
+		}
+		c := core.CompareValues(value, acc.value)
+		if !acc.seen || (def.op == AggregateMin && c < 0) || (def.op == AggregateMax && c > 0) {
+			acc.value = value
+			acc.seen = true
+		}
+	case AggregateFirst:
+		if !acc.seen {
+			acc.value = value
+			acc.seen = true
+		}
+	case AggregateLast:
+		acc.value = value
+	case AggregateCollect:
+		acc.values = append(acc.values, value)
+	case AggregateCountDistinct:
+		if value != nil {
+			acc.distinct[keyString(value)] = true
+		}
+	}
+	return nil
+}
+
+func (acc *accumulator) result(op string) any {
+	switch op {
+	case AggregateCount:
+		return acc.count
+	case AggregateSum:
+		if acc.integral {
+			return int64(acc.sum)
+		}
+		return acc.sum
+	case AggregateAvg:
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.sum / float64(acc.count)
+	case AggregateCollect:
+		if acc.values == nil {
+			return []any{}
+		}
+		return acc.values
+	case AggregateCountDistinct:
+		return int64(len(acc.distinct))
+	default:
+		return acc.value
+	}
+}
+
+func parseGroupBy(raw any) ([]groupKey, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'group_by' must be a list, got %T", raw)
+	}
+
+	keys := make([]groupKey, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			keys = append(keys, groupKey{name: v, value: recordValue{field: v}})
+		case map[string]any:
+			name, ok := v["name"].(string)
+			if !ok {
+				return nil, fmt.Errorf("group key requires a 'name', got %v", v)
+			}
+			value, ok, err := parseRecordValue(v)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				value = recordValue{field: name}
+			}
+			keys = append(keys, groupKey{name: name, value: value})
+		default:
+			return nil, fmt.Errorf("invalid group key %v", item)
+		}
+	}
+	return keys, nil
+}
+
+func parseAggregates(raw any) ([]aggregateDef, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'aggregates' must be a list, got %T", raw)
+	}
+
+	defs := make([]aggregateDef, 0, len(items))
+	for _, item := range items {
+		v, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("each aggregate must be a map, got %T", item)
+		}
+		name, ok := v["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("aggregate requires a 'name', got %v", v)
+		}
+		op, ok := v["op"].(string)
+		if !ok {
+			return nil, fmt.Errorf("aggregate '%s' requires an 'op'", name)
+		}
+
+		value, hasValue, err := parseRecordValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case AggregateCount:
+		case AggregateSum, AggregateMin, AggregateMax, AggregateAvg, AggregateFirst, AggregateLast, AggregateCollect, AggregateCountDistinct:
+			if !hasValue {
+				return nil, fmt.Errorf("aggregate '%s' requires a 'field' or 'value'", name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown aggregate operation: %s", op)
+		}
+
+		defs = append(defs, aggregateDef{name: name, op: op, value: value, hasValue: hasValue})
+	}
+	return defs, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("aggregate", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		groupBy, err := parseGroupBy(config["group_by"])
+		if err != nil {
+			return nil, err
+		}
+
+		rawAggregates, ok := config["aggregates"]
+		if !ok {
+			return nil, core.ErrMissingConfig("aggregates")
+		}
+		aggregates, err := parseAggregates(rawAggregates)
+		if err != nil {
+			return nil, err
+		}
+
+		return &AggregateStep{
+			name:       name,
+			list:       core.InterpolateValue[[]any]{Raw: list},
+			groupBy:    groupBy,
+			aggregates: aggregates,
+		}, nil
+	})
+}
@@ -0,0 +1,101 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+)
+
+const (
+	DedupeKeepFirst = "first"
+	DedupeKeepLast  = "last"
+	DedupeKeepMax   = "max"
+)
+
+// DedupeStep removes records with duplicate keys. The kept record takes the
+// position of the first occurrence of its key.
+type DedupeStep struct {
+	name  string
+	list  core.InterpolateValue[[]any]
+	keys  []*core.Expression
+	keep  string
+	field string
+}
+
+func (d *DedupeStep) Name() string { return d.name }
+
+func (d *DedupeStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := d.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", d.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]int)
+	results := make([]any, 0, len(list))
+	for i, record := range list {
+		key, err := recordKey(ev, d.keys, record, i)
+		if err != nil {
+			return nil, fmt.Errorf("key failed on record %d: %w", i, err)
+		}
+
+		pos, seen := positions[key]
+		if !seen {
+			positions[key] = len(results)
+			results = append(results, record)
+			continue
+		}
+
+		switch d.keep {
+		case DedupeKeepLast:
+			results[pos] = record
+		case DedupeKeepMax:
+			if core.CompareValues(fieldValue(record, d.field), fieldValue(results[pos], d.field)) > 0 {
+				results[pos] = record
+			}
+		}
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+func init() {
+	pipeline.RegisterStepType("dedupe", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		keys, err := compileKeys(config, "key")
+		if err != nil {
+			return nil, err
+		}
+
+		keep, err := core.ConfigString(config, "keep", DedupeKeepFirst)
+		if err != nil {
+			return nil, err
+		}
+
+		field, err := core.ConfigString(config, "field", "")
+		if err != nil {
+			return nil, err
+		}
+
+		switch keep {
+		case DedupeKeepFirst, DedupeKeepLast:
+		case DedupeKeepMax:
+			if field == "" {
+				return nil, fmt.Errorf("keep 'max' requires 'field'")
+			}
+		default:
+			return nil, fmt.Errorf("unknown keep strategy: %s", keep)
+		}
+
+		return &DedupeStep{name: name, list: core.InterpolateValue[[]any]{Raw: list}, keys: keys, keep: keep, field: field}, nil
+	})
+}
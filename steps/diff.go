@@ -0,0 +1,161 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+)
+
+// DiffStep compares a previous and a current record list by key and emits the
+// records on the added, removed, changed and unchanged outputs.
+type DiffStep struct {
+	name     string
+	previous core.InterpolateValue[[]any]
+	current  core.InterpolateValue[[]any]
+	keys     []*core.Expression
+	compare  []string
+}
+
+func (d *DiffStep) Name() string { return d.name }
+
+func (d *DiffStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	previous, err := d.previous.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("previous", d.previous.Raw)
+	}
+	current, err := d.current.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("current", d.current.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are expected to be unique, later duplicates are ignored
+	index := make(map[string]any, len(previous))
+	var previousKeys []string
+	for i, record := range previous {
+		key, err := recordKey(ev, d.keys, record, i)
+		if err != nil {
+			return nil, fmt.Errorf("key failed on previous record %d: %w", i, err)
+		}
+		if _, ok := index[key]; !ok {
+			index[key] = record
+			previousKeys = append(previousKeys, key)
+		}
+	}
+
+	added, changed, unchanged := []any{}, []any{}, []any{}
+	seen := make(map[string]bool, len(current))
+	for i, record := range current {
+		key, err := recordKey(ev, d.keys, record, i)
+		if err != nil {
+			return nil, fmt.Errorf("key failed on current record %d: %w", i, err)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		old, ok := index[key]
+		switch {
+		case !ok:
+			added = append(added, record)
+		case d.equal(old, record):
+			unchanged = append(unchanged, record)
+		default:
+			changed = append(changed, record)
+		}
+	}
+
+	removed := []any{}
+	for _, key := range previousKeys {
+		if !seen[key] {
+			removed = append(removed, index[key])
+		}
+	}
+
+	return map[string]*core.Data{
+		"added":     {Value: added},
+		"removed":   {Value: removed},
+		"changed":   {Value: changed},
+		"unchanged": {Value: unchanged},
+	}, nil
+}
+
+func (d *DiffStep) equal(a, b any) bool {
+	if len(d.compare) == 0 {
+		return valuesEqual(a, b)
+	}
+	for _, field := range d.compare {
+		if !valuesEqual(fieldValue(a, field), fieldValue(b, field)) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesEqual compares two values deeply, treating numbers of different Go types as equal.
+func valuesEqual(a, b any) bool {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !valuesEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !valuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return keyString(a) == keyString(b)
+	}
+}
+
+func init() {
+	pipeline.RegisterStepType("diff", func(name string, config map[string]any) (core.Step, error) {
+		previous, ok := config["previous"]
+		if !ok {
+			return nil, core.ErrMissingConfig("previous")
+		}
+		current, ok := config["current"]
+		if !ok {
+			return nil, core.ErrMissingConfig("current")
+		}
+
+		keys, err := compileKeys(config, "key")
+		if err != nil {
+			return nil, err
+		}
+
+		compare, err := core.ConfigStringSlice(config, "compare")
+		if err != nil {
+			return nil, err
+		}
+
+		return &DiffStep{
+			name:     name,
+			previous: core.InterpolateValue[[]any]{Raw: previous},
+			current:  core.InterpolateValue[[]any]{Raw: current},
+			keys:     keys,
+			compare:  compare,
+		}, nil
+	})
+}
@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go-etl/core"
 	"go-etl/pipeline"
 	"maps"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	sdk "go-etl-sdk"
 )
@@ -18,6 +22,8 @@ import (
 type ExecPluginStep struct {
 	name          string
 	command       string
+	manifest      string
+	rateLimiter   string
 	otherConfig   map[string]any
 	configuration sdk.Configuration
 }
@@ -25,59 +31,191 @@ type ExecPluginStep struct {
 func (e *ExecPluginStep) Name() string { return e.name }
 
 func (e *ExecPluginStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	resolvedConfig, err := e.resolveInputs(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := core.WaitRateLimiter(ctx, e.rateLimiter); err != nil {
+		return nil, err
+	}
+
+	if e.configuration.Protocol == sdk.ProtocolJSONRPC {
+		return e.call(ctx, state, resolvedConfig)
+	}
+
+	payload, err := json.Marshal(resolvedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := state.Logger.With("step", e.name)
+	stderr := newPluginLogWriter(logger)
+	cmd := exec.CommandContext(ctx, e.command)
+	cmd.Stdin = bytes.NewReader(payload)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	stderr.Flush()
+	logger.Debug("exec_plugin step output", "output", out.String())
+	result, resultErr := decodeResult(e.name, out.Bytes())
+	if err != nil {
+		// A failing plugin written with the SDK reports why on stdout
+		var pluginErr *core.PluginError
+		if errors.As(resultErr, &pluginErr) && pluginErr.Code != "no_output" {
+			return nil, pluginErr
+		}
+		return nil, fmt.Errorf("plugin step '%s' failed: %w", e.name, err)
+	}
+	if resultErr != nil {
+		return nil, resultErr
+	}
+
+	return pluginOutputs(e.manifest, e.configuration, result)
+}
+
+// resolveInputs returns the plugin configuration: the step values, resolved
+// when the input is interpolated, secret references and manifest defaults.
+func (e *ExecPluginStep) resolveInputs(state *core.PipelineState) (map[string]any, error) {
 	resolvedConfig := make(map[string]any)
 
 	for key, value := range e.configuration.Inputs {
-		conf := e.otherConfig[key]
+		conf, ok := e.otherConfig[key]
+		// Resolved secrets are used as is, never interpolated
+		interpolate := value.Interpolation
+		if !ok {
+			ref, isSecret := e.otherConfig[key+"_secret"].(string)
+			if !value.Secret || !isSecret {
+				resolvedConfig[key] = value.Default
+				continue
+			}
+			secret, err := core.ResolveSecret(ref)
+			if err != nil {
+				return nil, fmt.Errorf("input '%s': %w", key, err)
+			}
+			conf = secret
+			interpolate = false
+		}
+
 		resolvedConfig[key] = conf
-		if value.Interpolation {
+		if value.Type == sdk.TypeConnection {
+			// Named connections are passed to the plugin as their DSN
+			if connectionName, ok := conf.(string); ok {
+				if connection, ok := state.Connections.Lookup(connectionName); ok {
+					resolvedConfig[key] = connection.DSN
+					continue
+				}
+			}
+		}
+		if interpolate {
 
-			interpolatedValue := core.InterpolateFromType(conf, value.Type)
+			// Manifest types describe the plugin input, they are not engine conversions
+			interpolatedValue := core.InterpolateValue[any]{Raw: conf}
 			v, err := interpolatedValue.Resolve(state)
 			if err != nil {
+				if value.Secret {
+					return nil, core.ErrInterpolate(key, "the secret value")
+				}
 				return nil, core.ErrInterpolate(key, conf)
 			}
 			resolvedConfig[key] = v
 		}
+		// Plain values were checked when the step was created
+		if (interpolate || !ok) && (resolvedConfig[key] != nil || value.Required) {
+			if err := checkValue(value.Type, value, resolvedConfig[key]); err != nil {
+				return nil, fmt.Errorf("input '%s' of %s %w", key, e.manifest, err)
+			}
+		}
 	}
+	return resolvedConfig, nil
+}
 
-	payload, err := json.Marshal(resolvedConfig)
+// call runs the step on the persistent process of the plugin.
+func (e *ExecPluginStep) call(ctx context.Context, state *core.PipelineState, config map[string]any) (map[string]*core.Data, error) {
+	logger := state.Logger.With("step", e.name)
+	out, err := hostFor(e.command).call(ctx, logger, config)
 	if err != nil {
-		return nil, err
+		return nil, callError(e.name, err)
 	}
 
-	cmd := exec.CommandContext(ctx, e.command)
-	cmd.Stdin = bytes.NewReader(payload)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
+	var result any
+	logger.Debug("exec_plugin step output", "output", string(out))
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, err
+	}
+	return pluginOutputs(e.manifest, e.configuration, result)
+}
 
-	if err := cmd.Run(); err != nil {
+// newPluginStep creates a step running the plugin command described by the
+// manifest configuration. Plain input values are checked against the manifest.
+func newPluginStep(name, commandPath, manifest string, configuration sdk.Configuration, config map[string]any) (core.Step, error) {
+	if err := validateManifest(manifest, configuration); err != nil {
 		return nil, err
 	}
 
-	var result any
-	state.Logger.Debug("exec_plugin step output", "name", e.name, "output", out.String())
-	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+	rateLimiter, err := core.ConfigString(config, "rate_limiter", "")
+	if err != nil {
 		return nil, err
 	}
+	otherConfig := maps.Clone(config)
+	delete(otherConfig, "command")
+	delete(otherConfig, "rate_limiter")
+
+	switch configuration.Protocol {
+	case "", sdk.ProtocolExec:
+	case sdk.ProtocolJSONRPC:
+		// Steps share the plugin process by its absolute path
+		if commandPath, err = filepath.Abs(commandPath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown plugin protocol '%s'", manifest, configuration.Protocol)
+	}
 
-	return core.CreateDefaultResultData(result), nil
+	for key, value := range otherConfig {
+		input, ok := configuration.Inputs[key]
+		if !ok {
+			secretOf, isRef := strings.CutSuffix(key, "_secret")
+			if secret, declared := configuration.Inputs[secretOf]; isRef && declared && secret.Secret {
+				if _, ok := value.(string); !ok {
+					return nil, fmt.Errorf("'%s' must be a secret reference, got %T", key, value)
+				}
+				continue
+			}
+			return nil, fmt.Errorf("unknown input '%s', %s declares %s", key, manifest, strings.Join(slices.Sorted(maps.Keys(configuration.Inputs)), ", "))
+		}
+		if input.Interpolation || (value == nil && !input.Required) {
+			continue
+		}
+		if err := checkValue(input.Type, input, value); err != nil {
+			return nil, fmt.Errorf("input '%s' of %s %w", key, manifest, err)
+		}
+	}
 
+	for key, value := range configuration.Inputs {
+		if !value.Required || value.Default != nil {
+			continue
+		}
+		_, exists := otherConfig[key]
+		_, secretExists := otherConfig[key+"_secret"]
+		if !exists && !(value.Secret && secretExists) {
+			return nil, fmt.Errorf("missing required input '%s' of %s", key, manifest)
+		}
+	}
+
+	return &ExecPluginStep{name: name, command: commandPath, manifest: manifest, rateLimiter: rateLimiter, otherConfig: otherConfig, configuration: configuration}, nil
 }
 
 func init() {
 	pipeline.RegisterStepType("plugin", func(name string, config map[string]any) (core.Step, error) {
 		commandPath, ok := config["command"].(string)
 		if !ok {
-			return nil, errors.New("missing 'path' in file step")
+			return nil, core.ErrMissingConfig("command")
 		}
-		otherConfig := maps.Clone(config)
-		delete(otherConfig, "command")
 
-		_, err := os.Stat(commandPath)
-
-		if err != nil {
+		if _, err := os.Stat(commandPath); err != nil {
 			return nil, err
 		}
 
@@ -92,14 +230,6 @@ func init() {
 			return nil, errors.New("failed to read plugin settings: " + err.Error())
 		}
 
-		for key, value := range configuration.Inputs {
-			if value.Required {
-				if _, exists := otherConfig[key]; !exists {
-					return nil, errors.New("missing required input: " + key)
-				}
-			}
-		}
-
-		return &ExecPluginStep{name: name, command: commandPath, otherConfig: otherConfig, configuration: configuration}, nil
+		return newPluginStep(name, commandPath, settingsPath, configuration, config)
 	})
 }
@@ -0,0 +1,62 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+)
+
+// FilterStep keeps the records of a list for which condition is true.
+// The condition is evaluated with the current record bound to `record`.
+type FilterStep struct {
+	name      string
+	list      core.InterpolateValue[[]any]
+	condition *core.Expression
+}
+
+func (f *FilterStep) Name() string { return f.name }
+
+func (f *FilterStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := f.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", f.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]any, 0, len(list))
+	for i, record := range list {
+		if err := bindRecord(ev, record, i); err != nil {
+			return nil, err
+		}
+		keep, err := ev.EvalBool(f.condition)
+		if err != nil {
+			return nil, fmt.Errorf("condition failed on record %d: %w", i, err)
+		}
+		if keep {
+			filtered = append(filtered, record)
+		}
+	}
+
+	return core.CreateDefaultResultData(filtered), nil
+}
+
+func init() {
+	pipeline.RegisterStepType("filter", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		condition, err := compileExpression(config, "condition")
+		if err != nil {
+			return nil, err
+		}
+
+		return &FilterStep{name: name, list: core.InterpolateValue[[]any]{Raw: list}, condition: condition}, nil
+	})
+}
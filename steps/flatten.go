@@ -0,0 +1,298 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// FlattenStep turns nested objects into records with dotted keys. Arrays of
+// objects are exploded into one row per element, repeating the parent fields.
+type FlattenStep struct {
+	name      string
+	list      core.InterpolateValue[any]
+	separator string
+	explode   []string
+	maxDepth  int
+}
+
+func (f *FlattenStep) Name() string { return f.name }
+
+func (f *FlattenStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	value, err := f.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", f.list.Raw)
+	}
+
+	// A single document is flattened like a list with one record
+	items, ok := value.([]any)
+	if !ok {
+		if records, ok := core.RecordList(value); ok {
+			items = make([]any, len(records))
+			for i, r := range records {
+				items[i] = r
+			}
+		} else {
+			items = []any{value}
+		}
+	}
+
+	results := make([]any, 0, len(items))
+	for i, item := range items {
+		record, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object, got %T", i, item)
+		}
+		for _, row := range f.flatten([]map[string]any{{}}, "", record, 0) {
+			results = append(results, row)
+		}
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+// flatten adds the fields of value under prefix to every row, returning more
+// rows when an array is exploded.
+func (f *FlattenStep) flatten(rows []map[string]any, prefix string, value map[string]any, depth int) []map[string]any {
+	for _, key := range slices.Sorted(maps.Keys(value)) {
+		path := prefix + key
+		switch v := value[key].(type) {
+		case map[string]any:
+			if f.maxDepth > 0 && depth+1 >= f.maxDepth {
+				setAll(rows, path, v)
+				continue
+			}
+			rows = f.flatten(rows, path+f.separator, v, depth+1)
+		case []any:
+			if !f.shouldExplode(path, v) {
+				setAll(rows, path, v)
+				continue
+			}
+			if len(v) == 0 {
+				continue
+			}
+			var exploded []map[string]any
+			for _, row := range rows {
+				for _, element := range v {
+					copies := []map[string]any{maps.Clone(row)}
+					if obj, ok := element.(map[string]any); ok {
+						copies = f.flatten(copies, path+f.separator, obj, depth+1)
+					} else {
+						copies[0][path] = element
+					}
+					exploded = append(exploded, copies...)
+				}
+			}
+			rows = exploded
+		default:
+			setAll(rows, path, v)
+		}
+	}
+	return rows
+}
+
+// shouldExplode explodes the configured paths, or every array of objects when none is configured.
+func (f *FlattenStep) shouldExplode(path string, values []any) bool {
+	if len(f.explode) > 0 {
+		return slices.Contains(f.explode, path)
+	}
+	if len(values) == 0 {
+		return false
+	}
+	_, isObject := values[0].(map[string]any)
+	return isObject
+}
+
+func setAll(rows []map[string]any, key string, value any) {
+	for _, row := range rows {
+		row[key] = value
+	}
+}
+
+// NestStep groups flat rows into parent objects holding an array of child objects.
+type NestStep struct {
+	name        string
+	list        core.InterpolateValue[[]any]
+	keys        []string
+	children    string
+	childPrefix string
+	separator   string
+	unflatten   bool
+}
+
+func (n *NestStep) Name() string { return n.name }
+
+func (n *NestStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := n.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", n.list.Raw)
+	}
+
+	parents := make(map[string]map[string]any)
+	var order []string
+	for i, item := range list {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object, got %T", i, item)
+		}
+
+		keyValues := make([]any, len(n.keys))
+		for k, key := range n.keys {
+			keyValues[k] = row[key]
+		}
+		id := keyString(keyValues...)
+
+		parent, ok := parents[id]
+		if !ok {
+			parent = make(map[string]any)
+			for _, key := range n.keys {
+				parent[key] = row[key]
+			}
+			parent[n.children] = []any{}
+			parents[id] = parent
+			order = append(order, id)
+		}
+
+		child := make(map[string]any)
+		empty := true
+		for field, value := range row {
+			if slices.Contains(n.keys, field) {
+				continue
+			}
+			if n.childPrefix != "" {
+				name, isChild := strings.CutPrefix(field, n.childPrefix)
+				if !isChild {
+					// Other parent fields keep their first value
+					if _, ok := parent[field]; !ok {
+						parent[field] = value
+					}
+					continue
+				}
+				field = name
+			}
+			child[field] = value
+			if value != nil {
+				empty = false
+			}
+		}
+
+		// Rows from a parent with no children only carry null child fields
+		if !empty {
+			if n.unflatten {
+				child = unflattenRecord(child, n.separator)
+			}
+			parent[n.children] = append(parent[n.children].([]any), child)
+		}
+	}
+
+	results := make([]any, 0, len(order))
+	for _, id := range order {
+		parent := parents[id]
+		if n.unflatten {
+			parent = unflattenRecord(parent, n.separator)
+		}
+		results = append(results, parent)
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+// unflattenRecord turns dotted keys back into nested objects.
+func unflattenRecord(record map[string]any, separator string) map[string]any {
+	out := make(map[string]any, len(record))
+	for _, key := range slices.Sorted(maps.Keys(record)) {
+		parts := strings.Split(key, separator)
+		target := out
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = record[key]
+	}
+	return out
+}
+
+func init() {
+	pipeline.RegisterStepType("flatten", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		separator, err := core.ConfigString(config, "separator", ".")
+		if err != nil {
+			return nil, err
+		}
+
+		explode, err := core.ConfigStringSlice(config, "explode")
+		if err != nil {
+			return nil, err
+		}
+
+		maxDepth, err := core.ConfigInt(config, "max_depth", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FlattenStep{
+			name:      name,
+			list:      core.InterpolateValue[any]{Raw: list},
+			separator: separator,
+			explode:   explode,
+			maxDepth:  maxDepth,
+		}, nil
+	})
+
+	pipeline.RegisterStepType("nest", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		keys, err := core.ConfigStringSlice(config, "key")
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, core.ErrMissingConfig("key")
+		}
+
+		children, ok := config["children"].(string)
+		if !ok {
+			return nil, core.ErrMissingConfig("children")
+		}
+
+		childPrefix, err := core.ConfigString(config, "child_prefix", "")
+		if err != nil {
+			return nil, err
+		}
+
+		separator, err := core.ConfigString(config, "separator", ".")
+		if err != nil {
+			return nil, err
+		}
+
+		unflatten, err := core.ConfigBool(config, "unflatten", true)
+		if err != nil {
+			return nil, err
+		}
+
+		return &NestStep{
+			name:        name,
+			list:        core.InterpolateValue[[]any]{Raw: list},
+			keys:        keys,
+			children:    children,
+			childPrefix: childPrefix,
+			separator:   separator,
+			unflatten:   unflatten,
+		}, nil
+	})
+}
@@ -5,14 +5,21 @@ import (
 	"fmt"
 	"go-etl/core"
 	"go-etl/pipeline"
-
-	"gopkg.in/yaml.v3"
+	"log/slog"
+	"sync"
 )
 
+// ForeachStep runs a sub-pipeline for every item of a list. Sub-steps read
+// the item as ctx.foreach.item and its position as ctx.foreach.index, and can
+// read the results of the parent pipeline.
 type ForeachStep struct {
-	name     string
-	list     core.InterpolateValue[[]any]
-	subSteps []pipeline.StepConfig
+	name            string
+	list            core.InterpolateValue[[]any]
+	pipeline        *pipeline.Pipeline
+	parallelism     int
+	collectStep     string
+	collectOutput   string
+	continueOnError bool
 }
 
 func (f *ForeachStep) Name() string { return f.name }
@@ -23,37 +30,96 @@ func (f *ForeachStep) Run(ctx context.Context, state *core.PipelineState) (map[s
 		return nil, fmt.Errorf("failed to resolve list in foreach step: %v", err)
 	}
 
-	pipelineConfig := pipeline.PipelineConfig{
-		Steps: f.subSteps,
-	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	pipeline, err := pipeline.LoadPipeline(pipelineConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load substeps pipeline: %v", err)
-	}
+	collected := make([]any, len(list))
+	found := make([]bool, len(list))
+	itemErrors := make([]error, len(list))
+	var mu sync.Mutex
+	var firstErr error
 
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, f.parallelism)
 	for i, item := range list {
-		subState := &core.PipelineState{Results: make(map[string]map[string]*core.Data)}
-		subState.Set("foreach", map[string]*core.Data{
-			"item":  {Value: item},
-			"index": {Value: i},
-		})
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 
-		pipeline.SetState(subState)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
 
-		err = pipeline.Run(ctx, state.Logger)
-		if err != nil {
-			return nil, fmt.Errorf("foreach substep failed: %v", err)
+			subState, err := runSubPipeline(ctx, f.pipeline, state, "foreach", map[string]*core.Data{
+				"item":  {Value: item},
+				"index": {Value: i},
+			})
+			if err != nil {
+				itemErrors[i] = err
+				if !f.continueOnError {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("foreach item %d failed: %w", i, err)
+					}
+					mu.Unlock()
+					cancel()
+				}
+				return
+			}
+			if f.collectStep != "" {
+				collected[i], found[i] = getData(subState, f.collectStep, f.collectOutput)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var errorList []any
+	for i, err := range itemErrors {
+		if err == nil {
+			continue
+		}
+		state.Logger.Warn("Foreach item failed", slog.String("step", f.name), slog.Int("index", i), slog.Any("error", err))
+		errorList = append(errorList, map[string]any{"index": i, "item": list[i], "error": err.Error()})
+	}
+	// A cancelled run stops the loop before every item ran
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]*core.Data{"default": {Value: fmt.Sprintf("processed %d items", len(list))}}
+	if f.collectStep != "" {
+		// Failed and skipped items are left out of the collected output
+		values := make([]any, 0, len(list))
+		for i, value := range collected {
+			if found[i] {
+				values = append(values, value)
+			}
+		}
+		outputs["default"] = &core.Data{Value: values}
+	}
+	if f.continueOnError {
+		if errorList == nil {
+			errorList = []any{}
 		}
+		outputs["errors"] = &core.Data{Value: errorList}
 	}
-	return map[string]*core.Data{"default": {Value: fmt.Sprintf("processed %d items", len(list))}}, nil
+	return outputs, nil
 }
 
 func init() {
 	pipeline.RegisterStepType("foreach", func(name string, config map[string]any) (core.Step, error) {
-		stepsCfg, ok := config["steps"].([]any)
-		if !ok {
-			return nil, fmt.Errorf("foreach requires 'steps' array")
+		subSteps, err := parseSubSteps(config)
+		if err != nil {
+			return nil, err
 		}
 
 		list, ok := config["list"]
@@ -61,27 +127,38 @@ func init() {
 			return nil, fmt.Errorf("foreach requires 'list' to iterate over")
 		}
 
-		var subSteps []pipeline.StepConfig
-
-		for _, raw := range stepsCfg {
-			m, ok := raw.((map[string]any))
-			if !ok {
-				return nil, fmt.Errorf("invalid substep format")
-			}
+		sub, err := loadSubPipeline(subSteps)
+		if err != nil {
+			return nil, err
+		}
 
-			subStepString, err := yaml.Marshal(m)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal substep config: %v", err)
-			}
+		parallelism, err := core.ConfigInt(config, "parallelism", 1)
+		if err != nil {
+			return nil, err
+		}
+		if parallelism < 1 {
+			return nil, fmt.Errorf("'parallelism' must be at least 1")
+		}
 
-			var subStep pipeline.StepConfig
-			if err := yaml.Unmarshal(subStepString, &subStep); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal substep config: %v", err)
-			}
+		collect, err := core.ConfigString(config, "collect", "")
+		if err != nil {
+			return nil, err
+		}
+		collectStep, collectOutput := parseOutputRef(collect)
 
-			subSteps = append(subSteps, subStep)
+		continueOnError, err := core.ConfigBool(config, "continue_on_error", false)
+		if err != nil {
+			return nil, err
 		}
 
-		return &ForeachStep{name: name, list: core.InterpolateValue[[]any]{Raw: list}, subSteps: subSteps}, nil
+		return &ForeachStep{
+			name:            name,
+			list:            core.InterpolateValue[[]any]{Raw: list},
+			pipeline:        sub,
+			parallelism:     parallelism,
+			collectStep:     collectStep,
+			collectOutput:   collectOutput,
+			continueOnError: continueOnError,
+		}, nil
 	})
 }
@@ -0,0 +1,246 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-etl/core"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthAPIKey = "api_key"
+	AuthOAuth2 = "oauth2"
+)
+
+// tokenExpiryMargin renews cached tokens shortly before they expire.
+const tokenExpiryMargin = 30 * time.Second
+
+// httpAuth authenticates requests. Credentials are read when the step runs,
+// either from the plain value or from the `<key>_secret` reference.
+type httpAuth struct {
+	kind   string
+	config map[string]any
+	in     string // header or query, for API keys
+	name   string // header or query parameter name, for API keys
+}
+
+// oauthToken is the cached token of a client. Its lock is held while the token
+// is requested, so that concurrent steps of the client wait for one request.
+type oauthToken struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// oauthTokens caches client-credentials tokens for the whole process.
+var oauthTokens = struct {
+	sync.Mutex
+	tokens map[string]*oauthToken
+}{tokens: make(map[string]*oauthToken)}
+
+// cachedToken returns the cache entry of the client credentials key.
+func cachedToken(key string) *oauthToken {
+	oauthTokens.Lock()
+	defer oauthTokens.Unlock()
+	cached, ok := oauthTokens.tokens[key]
+	if !ok {
+		cached = &oauthToken{}
+		oauthTokens.tokens[key] = cached
+	}
+	return cached
+}
+
+// apply adds the credentials to req.
+func (a *httpAuth) apply(ctx context.Context, client *http.Client, req *http.Request) error {
+	switch a.kind {
+	case AuthBasic:
+		username, err := a.credential("username")
+		if err != nil {
+			return err
+		}
+		password, err := a.credential("password")
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+	case AuthBearer:
+		token, err := a.credential("token")
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case AuthAPIKey:
+		key, err := a.credential("value")
+		if err != nil {
+			return err
+		}
+		if a.in == "query" {
+			query := req.URL.Query()
+			query.Set(a.name, key)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(a.name, key)
+		}
+	case AuthOAuth2:
+		token, err := a.token(ctx, client)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// credential returns the value of key, or the secret referenced by `<key>_secret`.
+func (a *httpAuth) credential(key string) (string, error) {
+	if ref, ok := a.config[key+"_secret"].(string); ok {
+		return core.ResolveSecret(ref)
+	}
+	if value, ok := a.config[key].(string); ok {
+		return value, nil
+	}
+	return "", core.ErrMissingConfig("auth." + key)
+}
+
+// clientCredentials identify the cached token of an OAuth2 client and scope.
+type clientCredentials struct {
+	tokenURL string
+	clientID string
+	scopes   []string
+}
+
+func (c clientCredentials) key() string {
+	return c.tokenURL + "\n" + c.clientID + "\n" + strings.Join(c.scopes, " ")
+}
+
+func (a *httpAuth) clientCredentials() (clientCredentials, error) {
+	var c clientCredentials
+	var err error
+	if c.tokenURL, err = a.credential("token_url"); err != nil {
+		return c, err
+	}
+	if c.clientID, err = a.credential("client_id"); err != nil {
+		return c, err
+	}
+	c.scopes, err = core.ConfigStringSlice(a.config, "scopes")
+	return c, err
+}
+
+// token returns a cached OAuth2 token, requesting a new one with the client
+// credentials grant when there is none or it is about to expire.
+func (a *httpAuth) token(ctx context.Context, client *http.Client) (string, error) {
+	creds, err := a.clientCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	cached := cachedToken(creds.key())
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if cached.value != "" && time.Now().Add(tokenExpiryMargin).Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	clientSecret, err := a.credential("client_secret")
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(creds.scopes) > 0 {
+		form.Set("scope", strings.Join(creds.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(creds.clientID), url.QueryEscape(clientSecret))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth2 token request failed with status code: %d", res.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode oauth2 token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token response has no access_token")
+	}
+
+	// Tokens without expiry are kept for an hour
+	expiresIn := time.Hour
+	if body.ExpiresIn > 0 {
+		expiresIn = time.Duration(body.ExpiresIn) * time.Second
+	}
+	cached.value, cached.expires = body.AccessToken, time.Now().Add(expiresIn)
+	return body.AccessToken, nil
+}
+
+// invalidate drops the cached token, e.g. after it was rejected.
+func (a *httpAuth) invalidate() {
+	if a.kind != AuthOAuth2 {
+		return
+	}
+	creds, err := a.clientCredentials()
+	if err != nil {
+		return
+	}
+	cached := cachedToken(creds.key())
+	cached.mu.Lock()
+	cached.value = ""
+	cached.mu.Unlock()
+}
+
+func parseAuth(raw any) (*httpAuth, error) {
+	config, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'auth' must be a map, got %T", raw)
+	}
+
+	kind, err := core.ConfigString(config, "type", "")
+	if err != nil {
+		return nil, err
+	}
+	a := &httpAuth{kind: kind, config: config}
+
+	switch kind {
+	case AuthBasic, AuthBearer:
+	case AuthAPIKey:
+		if a.in, err = core.ConfigString(config, "in", "header"); err != nil {
+			return nil, err
+		}
+		if a.in != "header" && a.in != "query" {
+			return nil, fmt.Errorf("api key must be in header or query, got %s", a.in)
+		}
+		if a.name, err = core.ConfigString(config, "name", "X-API-Key"); err != nil {
+			return nil, err
+		}
+	case AuthOAuth2:
+		if _, ok := config["token_url"]; !ok {
+			return nil, core.ErrMissingConfig("auth.token_url")
+		}
+	case "":
+		return nil, core.ErrMissingConfig("auth.type")
+	default:
+		return nil, fmt.Errorf("unknown auth type: %s", kind)
+	}
+	return a, nil
+}
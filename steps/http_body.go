@@ -0,0 +1,141 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const (
+	BodyJSON      = "json"
+	BodyForm      = "form"
+	BodyMultipart = "multipart"
+	BodyText      = "text"
+	BodyBinary    = "binary"
+)
+
+// encodeBody returns the request body and its default content type. The
+// returned reader is closed by the HTTP client.
+func encodeBody(bodyType string, body any) (io.Reader, string, error) {
+	switch bodyType {
+	case BodyJSON:
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal body: %w", err)
+		}
+		return bytes.NewReader(b), "application/json", nil
+	case BodyForm:
+		fields, ok := body.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("form body must be a map, got %T", body)
+		}
+		values := url.Values{}
+		for key, value := range fields {
+			addValues(values, key, value)
+		}
+		return bytes.NewReader([]byte(values.Encode())), "application/x-www-form-urlencoded", nil
+	case BodyMultipart:
+		fields, ok := body.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("multipart body must be a map, got %T", body)
+		}
+		return encodeMultipart(fields)
+	case BodyText:
+		if s, ok := body.(string); ok {
+			return bytes.NewReader([]byte(s)), "text/plain; charset=utf-8", nil
+		}
+		return bytes.NewReader([]byte(fmt.Sprint(body))), "text/plain; charset=utf-8", nil
+	case BodyBinary:
+		switch v := body.(type) {
+		case []byte:
+			return bytes.NewReader(v), "application/octet-stream", nil
+		case string:
+			return bytes.NewReader([]byte(v)), "application/octet-stream", nil
+		case map[string]any:
+			// The file is streamed rather than read in memory
+			path, ok := v["file"].(string)
+			if !ok {
+				return nil, "", fmt.Errorf("binary body map must contain a 'file' path")
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to open body file: %w", err)
+			}
+			return file, "application/octet-stream", nil
+		default:
+			return nil, "", fmt.Errorf("binary body must be bytes, a string or {file: path}, got %T", body)
+		}
+	default:
+		return nil, "", fmt.Errorf("unknown body type: %s", bodyType)
+	}
+}
+
+// encodeMultipart writes fields as form parts. Fields given as
+// {file: path, filename, content_type} are sent as file parts.
+func encodeMultipart(fields map[string]any) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		def, isFile := value.(map[string]any)
+		path, _ := def["file"].(string)
+		if !isFile || path == "" {
+			if err := writer.WriteField(name, fmt.Sprint(value)); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		filename, ok := def["filename"].(string)
+		if !ok {
+			filename = filepath.Base(path)
+		}
+		contentType, ok := def["content_type"].(string)
+		if !ok {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, name, filename))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := copyFile(part, path); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, writer.FormDataContentType(), nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open multipart file: %w", err)
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// addValues adds a query or form value; lists add the key once per element.
+func addValues(values url.Values, key string, value any) {
+	switch v := value.(type) {
+	case nil:
+	case []any:
+		for _, item := range v {
+			values.Add(key, fmt.Sprint(item))
+		}
+	default:
+		values.Add(key, fmt.Sprint(v))
+	}
+}
@@ -1,136 +1,354 @@
 package steps
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"go-etl/core"
 	"go-etl/pipeline"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
+// HTTPClientStep sends a request. The url, method, headers, query, body and
+// response_file strings are templates: "/orders/${ctx.order.id}".
 type HTTPClientStep struct {
-	name     string
-	url      string
-	method   string
-	headers  map[string]string
-	body     any
-	response string
-}
-
-type HTTPClientResponse struct {
-	StatusCode int
-	Headers    map[string]string
-	Body       any
+	name         string
+	url          string
+	method       string
+	headers      map[string]any
+	query        map[string]any
+	body         any
+	bodyType     string
+	response     string
+	responseFile string
+	acceptStatus []statusRange
+	pagination   *httpPagination
+	auth         *httpAuth
+	client       *http.Client
+	rateLimiter  string
+	maxRetries   int
+	retryDelay   time.Duration
 }
 
 func (h *HTTPClientStep) Name() string { return h.name }
 
 func (h *HTTPClientStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
-	client := &http.Client{}
-
-	var bodyData io.Reader = nil
-	contentType, ok := h.headers["Content-Type"]
-	if ok && contentType == "application/json" {
-		if h.body != nil {
-			bodyDataBytes, err := json.Marshal(h.body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal body: %w", err)
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.pagination != nil {
+		return h.paginate(ctx, ev)
+	}
+
+	_, responseData, err := h.do(ctx, ev, nil)
+	var status *statusError
+	if errors.As(err, &status) {
+		// Steps reading the default output are skipped, those reading step:error run
+		return map[string]*core.Data{"error": {Value: responseData}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return core.CreateDefaultResultData(responseData), nil
+}
+
+// do builds, authenticates and sends a request to u, see newRequest. A
+// rejected OAuth2 token is renewed and the request sent once more. A 429
+// response is retried after its Retry-After delay, or an exponential backoff,
+// during which the requests sharing the rate limiter are held too.
+func (h *HTTPClientStep) do(ctx context.Context, ev *core.Evaluator, u *url.URL) (*http.Request, *HTTPClientResponse, error) {
+	file, err := ev.Template(h.responseFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var limiter *core.RateLimiter
+	if h.rateLimiter != "" {
+		var ok bool
+		if limiter, ok = core.LookupRateLimiter(h.rateLimiter); !ok {
+			return nil, nil, fmt.Errorf("unknown rate limiter: %s", h.rateLimiter)
+		}
+	}
+
+	renewed := false
+	for retries := 0; ; {
+		req, err := h.newRequest(ctx, ev, u)
+		if err != nil {
+			return nil, nil, err
+		}
+		if h.auth != nil {
+			if err := h.auth.apply(ctx, h.client, req); err != nil {
+				closeBody(req)
+				return nil, nil, fmt.Errorf("authentication failed: %w", err)
+			}
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				closeBody(req)
+				return nil, nil, err
 			}
-			bodyData = io.NopCloser(bytes.NewReader(bodyDataBytes))
 		}
-	} else {
-		// For non-JSON bodies, we can use a different approach if needed
-		bodyData = nil // No body for non-JSON requests
-		if h.body != nil {
-			return nil, fmt.Errorf("body is not supported for non-JSON requests")
+
+		res, err := h.send(req, file)
+		var status *statusError
+		if !errors.As(err, &status) {
+			return req, res, err
+		}
+		switch {
+		case status.code == http.StatusUnauthorized && h.auth != nil && h.auth.kind == AuthOAuth2 && !renewed:
+			h.auth.invalidate()
+			renewed = true
+		case status.code == http.StatusTooManyRequests && retries < h.maxRetries:
+			delay := retryAfter(res.Headers["Retry-After"], h.retryDelay<<retries)
+			retries++
+			if limiter != nil {
+				limiter.Pause(delay)
+				continue
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		default:
+			return req, res, err
 		}
 	}
+}
 
-	// bodyData, err := json.Marshal(h.body)
-	// if err != nil {
-	// 	return nil, fmt.Errorf("failed to marshal body: %w", err)
-	// }
-	req, err := http.NewRequest(h.method, h.url, bodyData)
-	if err != nil {
-		return nil, err
+// closeBody releases the body of a request that is not sent, such as an
+// opened file. Sent bodies are closed by the client.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
 	}
+}
 
-	// Set headers
-	for key, value := range h.headers {
-		req.Header.Set(key, value)
+// retryAfter returns the delay of a Retry-After header, in seconds or as a
+// date, or def when there is none.
+func retryAfter(header string, def time.Duration) time.Duration {
+	if header == "" {
+		return def
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
 	}
+	return def
+}
 
-	res, err := client.Do(req)
+// resolveURL resolves the url and query templates.
+func (h *HTTPClientStep) resolveURL(ev *core.Evaluator) (*url.URL, error) {
+	rawURL, err := ev.Template(h.url)
 	if err != nil {
 		return nil, err
 	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url '%s': %w", rawURL, err)
+	}
+	if len(h.query) > 0 {
+		query, err := ev.TemplateValue(h.query)
+		if err != nil {
+			return nil, err
+		}
+		values := u.Query()
+		for key, value := range query.(map[string]any) {
+			addValues(values, key, value)
+		}
+		u.RawQuery = values.Encode()
+	}
+	return u, nil
+}
 
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP request failed with status code: %d", res.StatusCode)
+// newRequest resolves the templates of the step configuration and builds the
+// request. A non nil u replaces the configured url and query, e.g. for the next page.
+func (h *HTTPClientStep) newRequest(ctx context.Context, ev *core.Evaluator, u *url.URL) (*http.Request, error) {
+	method, err := ev.Template(h.method)
+	if err != nil {
+		return nil, err
 	}
 
-	responseData := &HTTPClientResponse{
-		StatusCode: res.StatusCode,
-		Headers:    make(map[string]string),
+	if u == nil {
+		if u, err = h.resolveURL(ev); err != nil {
+			return nil, err
+		}
 	}
 
-	for key, values := range res.Header {
-		responseData.Headers[key] = values[0] // Use the first value for simplicity
+	var body io.Reader
+	var contentType string
+	if h.body != nil {
+		resolved, err := ev.TemplateValue(h.body)
+		if err != nil {
+			return nil, err
+		}
+		if body, contentType, err = encodeBody(h.bodyType, resolved); err != nil {
+			return nil, err
+		}
 	}
 
-	switch h.response {
-	case "json":
-		var bodyData map[string]any
-		if err := json.NewDecoder(res.Body).Decode(&bodyData); err != nil {
-			return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
 		}
-		responseData.Body = bodyData
-	case "text":
-		var bodyData string
-		if err := json.NewDecoder(res.Body).Decode(&bodyData); err != nil {
-			return nil, fmt.Errorf("failed to decode text response: %w", err)
+		return nil, err
+	}
+
+	headers, err := ev.TemplateValue(h.headers)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	for key, value := range headers.(map[string]any) {
+		// Lists send the header once per value
+		if values, ok := value.([]any); ok {
+			for _, v := range values {
+				req.Header.Add(key, fmt.Sprint(v))
+			}
+			continue
 		}
-		responseData.Body = bodyData
+		req.Header.Set(key, fmt.Sprint(value))
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
 	}
+	return req, nil
+}
 
-	return core.CreateDefaultResultData(responseData), nil
+// stringMap reads a map config value such as headers or query. YAML produces
+// map[string]any, while Go callers may pass map[string]string.
+func stringMap(config map[string]any, key string) (map[string]any, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return v, nil
+	case map[string]string:
+		m := make(map[string]any, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a map, got %T", key, v)
+	}
 }
 
 func init() {
 	pipeline.RegisterStepType("http client", func(name string, config map[string]any) (core.Step, error) {
 		url, ok := config["url"].(string)
 		if !ok {
-			return nil, core.ErrMissingConfig("missing or invalid 'url' in HTTP client step")
+			return nil, core.ErrMissingConfig("url")
 		}
 
 		method, ok := config["method"].(string)
 		if !ok {
-			return nil, core.ErrMissingConfig("missing or invalid 'method' in HTTP client step")
+			return nil, core.ErrMissingConfig("method")
 		}
 
-		headers, ok := config["headers"].(map[string]string)
-		if !ok {
-			headers = make(map[string]string) // Default to empty headers if not provided
+		headers, err := stringMap(config, "headers")
+		if err != nil {
+			return nil, err
+		}
+		query, err := stringMap(config, "query")
+		if err != nil {
+			return nil, err
 		}
 
-		response, ok := config["response"].(string)
-		if !ok {
-			response = "json"
+		response, err := core.ConfigString(config, "response", ResponseJSON)
+		if err != nil {
+			return nil, err
+		}
+		switch response {
+		case ResponseJSON, ResponseText, ResponseBytes, ResponseFile:
+		default:
+			return nil, fmt.Errorf("unknown response type: %s", response)
+		}
+		responseFile, err := core.ConfigString(config, "response_file", "")
+		if err != nil {
+			return nil, err
+		}
+		if response == ResponseFile && responseFile == "" {
+			return nil, core.ErrMissingConfig("response_file")
+		}
+		acceptStatus, err := parseAcceptStatus(config["accept_status"])
+		if err != nil {
+			return nil, err
 		}
 
 		body := config["body"] // Body can be optional
 
+		bodyType, err := core.ConfigString(config, "body_type", BodyJSON)
+		if err != nil {
+			return nil, err
+		}
+		switch bodyType {
+		case BodyJSON, BodyForm, BodyMultipart, BodyText, BodyBinary:
+		default:
+			return nil, fmt.Errorf("unknown body type: %s", bodyType)
+		}
+
+		var auth *httpAuth
+		if raw, ok := config["auth"]; ok {
+			if auth, err = parseAuth(raw); err != nil {
+				return nil, err
+			}
+		}
+
+		client, err := newHTTPClient(config)
+		if err != nil {
+			return nil, err
+		}
+
+		rateLimiter, err := core.ConfigString(config, "rate_limiter", "")
+		if err != nil {
+			return nil, err
+		}
+		maxRetries, err := core.ConfigInt(config, "max_retries", 3)
+		if err != nil {
+			return nil, err
+		}
+		retryDelay, err := core.ConfigInt(config, "retry_delay_ms", 1000)
+		if err != nil {
+			return nil, err
+		}
+
+		var pagination *httpPagination
+		if raw, ok := config["pagination"]; ok {
+			if pagination, err = parsePagination(raw); err != nil {
+				return nil, err
+			}
+			if response != ResponseJSON {
+				return nil, fmt.Errorf("pagination requires a json response, got %s", response)
+			}
+		}
+
 		return &HTTPClientStep{
-			name:     name,
-			url:      url,
-			method:   method,
-			headers:  headers,
-			body:     body,
-			response: response,
+			name:         name,
+			url:          url,
+			method:       method,
+			headers:      headers,
+			query:        query,
+			body:         body,
+			bodyType:     bodyType,
+			response:     response,
+			responseFile: responseFile,
+			acceptStatus: acceptStatus,
+			pagination:   pagination,
+			auth:         auth,
+			client:       client,
+			rateLimiter:  rateLimiter,
+			maxRetries:   maxRetries,
+			retryDelay:   time.Duration(retryDelay) * time.Millisecond,
 		}, nil
 	})
 }
@@ -0,0 +1,233 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-etl/core"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	PagePage    = "page"
+	PageOffset  = "offset"
+	PageCursor  = "cursor"
+	PageLink    = "link"
+	PageNextURL = "next_url"
+)
+
+// httpPagination follows the pages of a response and concatenates their items.
+type httpPagination struct {
+	kind      string
+	items     string
+	maxPages  int
+	delay     time.Duration
+	param     string // query parameter holding the page number, offset or cursor
+	start     int
+	sizeParam string
+	size      int
+	path      string // response body path of the cursor or of the next url
+}
+
+var linkNext = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+// paginate requests every page and returns the items of all pages as the
+// default output, and the number of pages as the pages output. A page with a
+// status code that is not accepted is emitted as the error output.
+func (h *HTTPClientStep) paginate(ctx context.Context, ev *core.Evaluator) (map[string]*core.Data, error) {
+	p := h.pagination
+	next, err := h.resolveURL(ev)
+	if err != nil {
+		return nil, err
+	}
+	next = p.first(next)
+
+	items := []any{}
+	pages := 0
+	for next != nil && pages < p.maxPages {
+		if pages > 0 && p.delay > 0 {
+			select {
+			case <-time.After(p.delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		req, res, err := h.do(ctx, ev, next)
+		var status *statusError
+		if errors.As(err, &status) {
+			// As without pagination; the items of the previous pages are dropped so
+			// that an incomplete list is never taken for the whole one
+			return map[string]*core.Data{"error": {Value: res}}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pages+1, err)
+		}
+		pages++
+
+		pageItems, err := p.pageItems(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pages, err)
+		}
+		items = append(items, pageItems...)
+
+		if next, err = p.next(req.URL, res, len(pageItems), pages); err != nil {
+			return nil, fmt.Errorf("page %d: %w", pages, err)
+		}
+	}
+
+	return map[string]*core.Data{
+		"default": {Value: items},
+		"pages":   {Value: pages},
+	}, nil
+}
+
+// first sets the parameters of the first page.
+func (p *httpPagination) first(u *url.URL) *url.URL {
+	switch p.kind {
+	case PagePage, PageOffset:
+		u = withQuery(u, p.param, strconv.Itoa(p.start))
+		if p.sizeParam != "" && p.size > 0 {
+			u = withQuery(u, p.sizeParam, strconv.Itoa(p.size))
+		}
+	}
+	return u
+}
+
+// next returns the url of the page following current, or nil after the last page.
+func (p *httpPagination) next(current *url.URL, res *HTTPClientResponse, count, pages int) (*url.URL, error) {
+	switch p.kind {
+	case PagePage, PageOffset:
+		// An empty or partial page is the last one
+		if count == 0 || (p.size > 0 && count < p.size) {
+			return nil, nil
+		}
+		if p.kind == PagePage {
+			return withQuery(current, p.param, strconv.Itoa(p.start+pages)), nil
+		}
+		return withQuery(current, p.param, strconv.Itoa(p.start+pages*p.size)), nil
+	case PageCursor:
+		cursor := fieldValue(res.Body, p.path)
+		if cursor == nil || cursor == "" {
+			return nil, nil
+		}
+		// JSON numbers are decoded as floats, large cursors must not use the exponent notation
+		if f, ok := cursor.(float64); ok {
+			return withQuery(current, p.param, strconv.FormatFloat(f, 'f', -1, 64)), nil
+		}
+		return withQuery(current, p.param, fmt.Sprint(cursor)), nil
+	case PageNextURL:
+		next, _ := fieldValue(res.Body, p.path).(string)
+		return resolveNext(current, next)
+	case PageLink:
+		match := linkNext.FindStringSubmatch(res.Headers["Link"])
+		if match == nil {
+			return nil, nil
+		}
+		return resolveNext(current, match[1])
+	}
+	return nil, nil
+}
+
+// pageItems returns the list found at the items path of the response body.
+func (p *httpPagination) pageItems(body any) ([]any, error) {
+	value := body
+	if p.items != "" {
+		value = fieldValue(body, p.items)
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("items at '%s' is not a list, got %T", p.items, value)
+	}
+}
+
+func withQuery(u *url.URL, key, value string) *url.URL {
+	next := *u
+	query := next.Query()
+	query.Set(key, value)
+	next.RawQuery = query.Encode()
+	return &next
+}
+
+// resolveNext resolves a next page link, possibly relative, against the current url.
+func resolveNext(current *url.URL, next string) (*url.URL, error) {
+	if next == "" {
+		return nil, nil
+	}
+	u, err := current.Parse(next)
+	if err != nil {
+		return nil, fmt.Errorf("invalid next page url '%s': %w", next, err)
+	}
+	return u, nil
+}
+
+func parsePagination(raw any) (*httpPagination, error) {
+	config, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'pagination' must be a map, got %T", raw)
+	}
+
+	p := &httpPagination{}
+	var err error
+	if p.kind, err = core.ConfigString(config, "type", ""); err != nil {
+		return nil, err
+	}
+	if p.items, err = core.ConfigString(config, "items", ""); err != nil {
+		return nil, err
+	}
+	if p.maxPages, err = core.ConfigInt(config, "max_pages", 100); err != nil {
+		return nil, err
+	}
+	delay, err := core.ConfigInt(config, "delay_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	p.delay = time.Duration(delay) * time.Millisecond
+
+	// Defaults depending on the pagination type
+	param, sizeParam, start, size := "", "", 0, 0
+	switch p.kind {
+	case PagePage:
+		param, start = "page", 1
+	case PageOffset:
+		param, sizeParam, size = "offset", "limit", 100
+	case PageCursor:
+		param = "cursor"
+	case PageLink, PageNextURL:
+	case "":
+		return nil, core.ErrMissingConfig("pagination.type")
+	default:
+		return nil, fmt.Errorf("unknown pagination type: %s", p.kind)
+	}
+
+	if p.param, err = core.ConfigString(config, "param", param); err != nil {
+		return nil, err
+	}
+	if p.sizeParam, err = core.ConfigString(config, "size_param", sizeParam); err != nil {
+		return nil, err
+	}
+	if p.start, err = core.ConfigInt(config, "start", start); err != nil {
+		return nil, err
+	}
+	if p.size, err = core.ConfigInt(config, "size", size); err != nil {
+		return nil, err
+	}
+	if p.path, err = core.ConfigString(config, "path", ""); err != nil {
+		return nil, err
+	}
+
+	if p.kind == PageOffset && p.size <= 0 {
+		return nil, fmt.Errorf("offset pagination requires a positive 'size'")
+	}
+	if (p.kind == PageCursor || p.kind == PageNextURL) && p.path == "" {
+		return nil, core.ErrMissingConfig("pagination.path")
+	}
+	return p, nil
+}
@@ -0,0 +1,177 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ResponseJSON  = "json"
+	ResponseText  = "text"
+	ResponseBytes = "bytes"
+	ResponseFile  = "file"
+)
+
+// HTTPClientResponse is the default output of a request, or its error output
+// when the status code is not accepted.
+type HTTPClientResponse struct {
+	StatusCode   int
+	Headers      map[string]string   // first value of each header
+	HeaderValues map[string][]string // every value of each header
+	Body         any
+}
+
+// statusError reports a response whose status code is not accepted.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status code: %d", e.code)
+}
+
+// statusRange accepts the status codes from min to max included.
+type statusRange struct {
+	min, max int
+}
+
+// send sends req and decodes the response body. A response with a status code
+// that is not accepted is returned along with a statusError, its body decoded
+// as JSON when possible and as text otherwise. In file mode, the body is
+// streamed to file.
+func (h *HTTPClientStep) send(req *http.Request, file string) (*HTTPClientResponse, error) {
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	response := &HTTPClientResponse{
+		StatusCode:   res.StatusCode,
+		Headers:      make(map[string]string, len(res.Header)),
+		HeaderValues: res.Header,
+	}
+	for key, values := range res.Header {
+		response.Headers[key] = values[0]
+	}
+
+	if !h.accepts(res.StatusCode) {
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if response.Body, err = decodeJSON(b); err != nil {
+			response.Body = string(b)
+		}
+		return response, &statusError{code: res.StatusCode}
+	}
+
+	if h.response == ResponseFile {
+		if err := writeResponseFile(file, res.Body); err != nil {
+			return nil, err
+		}
+		response.Body = file
+		return response, nil
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	switch h.response {
+	case ResponseJSON:
+		if response.Body, err = decodeJSON(b); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON response: %w", err)
+		}
+	case ResponseText:
+		response.Body = string(b)
+	case ResponseBytes:
+		response.Body = b
+	}
+	return response, nil
+}
+
+// accepts reports whether code is one of the accepted status codes, 2xx by default.
+func (h *HTTPClientStep) accepts(code int) bool {
+	if len(h.acceptStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, r := range h.acceptStatus {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSON decodes a JSON document of any type; an empty body is nil.
+func decodeJSON(b []byte) (any, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	var body any
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeResponseFile streams body to path, creating its directory. A partial
+// file is removed on failure.
+func writeResponseFile(path string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create response directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create response file: %w", err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write response file: %w", err)
+	}
+	return file.Close()
+}
+
+// parseAcceptStatus reads a list of status codes and classes such as "2xx".
+func parseAcceptStatus(raw any) ([]statusRange, error) {
+	var items []any
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		items = v
+	case []int:
+		for _, code := range v {
+			items = append(items, code)
+		}
+	default:
+		return nil, fmt.Errorf("'accept_status' must be a list, got %T", raw)
+	}
+
+	ranges := make([]statusRange, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case int:
+			ranges = append(ranges, statusRange{v, v})
+		case string:
+			class, ok := strings.CutSuffix(strings.ToLower(v), "xx")
+			digit, err := strconv.Atoi(class)
+			if !ok || err != nil || len(class) != 1 {
+				return nil, fmt.Errorf("invalid status class '%s', expected e.g. 2xx", v)
+			}
+			ranges = append(ranges, statusRange{digit * 100, digit*100 + 99})
+		default:
+			return nil, fmt.Errorf("'accept_status' must contain status codes, got %T", item)
+		}
+	}
+	return ranges, nil
+}
@@ -0,0 +1,92 @@
+package steps
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go-etl/core"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// newHTTPClient builds the client of a step from its tls, proxy and timeout_ms
+// options. Without proxy, the HTTP_PROXY and HTTPS_PROXY variables are used.
+func newHTTPClient(config map[string]any) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if raw, ok := config["tls"]; ok {
+		tlsConfig, err := parseTLS(raw)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	proxy, err := core.ConfigString(config, "proxy", "")
+	if err != nil {
+		return nil, err
+	}
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url '%s': %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout, err := core.ConfigInt(config, "timeout_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport, Timeout: time.Duration(timeout) * time.Millisecond}, nil
+}
+
+func parseTLS(raw any) (*tls.Config, error) {
+	config, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'tls' must be a map, got %T", raw)
+	}
+
+	tlsConfig := &tls.Config{}
+	var err error
+	if tlsConfig.InsecureSkipVerify, err = core.ConfigBool(config, "insecure_skip_verify", false); err != nil {
+		return nil, err
+	}
+
+	caFile, err := core.ConfigString(config, "ca_file", "")
+	if err != nil {
+		return nil, err
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile, err := core.ConfigString(config, "cert_file", "")
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err := core.ConfigString(config, "key_file", "")
+	if err != nil {
+		return nil, err
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,250 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"maps"
+)
+
+const (
+	JoinInner = "inner"
+	JoinLeft  = "left"
+	JoinRight = "right"
+	JoinFull  = "full"
+	JoinAnti  = "anti"
+	JoinSemi  = "semi"
+)
+
+// JoinStep combines two record lists with a hash join on key expressions.
+// The right list is the build side of the hash table.
+type JoinStep struct {
+	name         string
+	left         core.InterpolateValue[[]any]
+	right        core.InterpolateValue[[]any]
+	leftKeys     []*core.Expression
+	rightKeys    []*core.Expression
+	joinType     string
+	leftPrefix   string
+	rightPrefix  string
+	maxBuildRows int
+}
+
+func (j *JoinStep) Name() string { return j.name }
+
+func (j *JoinStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	left, err := j.left.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("left", j.left.Raw)
+	}
+	right, err := j.right.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("right", j.right.Raw)
+	}
+
+	if j.maxBuildRows > 0 && len(right) > j.maxBuildRows {
+		state.Logger.Warn("Join build side exceeds memory threshold",
+			slog.String("step", j.name), slog.Int("rows", len(right)), slog.Int("max_build_rows", j.maxBuildRows))
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build
+	table := make(map[string][]int)
+	for i, record := range right {
+		key, ok, err := joinKey(ev, j.rightKeys, record, i)
+		if err != nil {
+			return nil, fmt.Errorf("right key failed on record %d: %w", i, err)
+		}
+		if ok {
+			table[key] = append(table[key], i)
+		}
+	}
+
+	// Probe
+	matchedRight := make([]bool, len(right))
+	results := make([]any, 0, len(left))
+	for i, record := range left {
+		key, ok, err := joinKey(ev, j.leftKeys, record, i)
+		if err != nil {
+			return nil, fmt.Errorf("left key failed on record %d: %w", i, err)
+		}
+		var matches []int
+		if ok {
+			matches = table[key]
+		}
+
+		switch j.joinType {
+		case JoinSemi:
+			if len(matches) > 0 {
+				results = append(results, record)
+			}
+			continue
+		case JoinAnti:
+			if len(matches) == 0 {
+				results = append(results, record)
+			}
+			continue
+		}
+
+		for _, m := range matches {
+			matchedRight[m] = true
+			results = append(results, j.merge(record, right[m]))
+		}
+		if len(matches) == 0 && (j.joinType == JoinLeft || j.joinType == JoinFull) {
+			results = append(results, j.merge(record, nil))
+		}
+	}
+
+	if j.joinType == JoinRight || j.joinType == JoinFull {
+		for i, record := range right {
+			if !matchedRight[i] {
+				results = append(results, j.merge(nil, record))
+			}
+		}
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+// merge combines two records. Fields present on both sides are renamed with the side prefixes.
+func (j *JoinStep) merge(left, right any) map[string]any {
+	l, _ := left.(map[string]any)
+	r, _ := right.(map[string]any)
+
+	out := maps.Clone(l)
+	if out == nil {
+		out = make(map[string]any, len(r))
+	}
+	for key, value := range r {
+		if _, conflict := l[key]; conflict {
+			if j.leftPrefix != "" {
+				delete(out, key)
+				out[j.leftPrefix+key] = l[key]
+			}
+			out[j.rightPrefix+key] = value
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// joinKey evaluates the key expressions of a record. Records with a null key never match.
+func joinKey(ev *core.Evaluator, keys []*core.Expression, record any, index int) (string, bool, error) {
+	values, err := evalKeys(ev, keys, record, index)
+	if err != nil {
+		return "", false, err
+	}
+	for _, value := range values {
+		if value == nil {
+			return "", false, nil
+		}
+	}
+	return keyString(values...), true, nil
+}
+
+// recordKey evaluates the key expressions of a record, null values included.
+func recordKey(ev *core.Evaluator, keys []*core.Expression, record any, index int) (string, error) {
+	values, err := evalKeys(ev, keys, record, index)
+	if err != nil {
+		return "", err
+	}
+	return keyString(values...), nil
+}
+
+func evalKeys(ev *core.Evaluator, keys []*core.Expression, record any, index int) ([]any, error) {
+	values := make([]any, len(keys))
+	for k, key := range keys {
+		value, err := evalRecord(ev, key, record, index)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = value
+	}
+	return values, nil
+}
+
+func compileKeys(config map[string]any, key string) ([]*core.Expression, error) {
+	if _, ok := config[key]; !ok {
+		return nil, core.ErrMissingConfig(key)
+	}
+	sources, err := core.ConfigStringSlice(config, key)
+	if err != nil {
+		return nil, err
+	}
+
+	expressions := make([]*core.Expression, len(sources))
+	for i, source := range sources {
+		if expressions[i], err = core.CompileExpression(source); err != nil {
+			return nil, err
+		}
+	}
+	return expressions, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("join", func(name string, config map[string]any) (core.Step, error) {
+		left, ok := config["left"]
+		if !ok {
+			return nil, core.ErrMissingConfig("left")
+		}
+		right, ok := config["right"]
+		if !ok {
+			return nil, core.ErrMissingConfig("right")
+		}
+
+		leftKeys, err := compileKeys(config, "left_key")
+		if err != nil {
+			return nil, err
+		}
+		rightKeys, err := compileKeys(config, "right_key")
+		if err != nil {
+			return nil, err
+		}
+		if len(leftKeys) != len(rightKeys) {
+			return nil, fmt.Errorf("'left_key' and 'right_key' must have the same number of keys")
+		}
+
+		joinType, err := core.ConfigString(config, "type", JoinInner)
+		if err != nil {
+			return nil, err
+		}
+		switch joinType {
+		case JoinInner, JoinLeft, JoinRight, JoinFull, JoinAnti, JoinSemi:
+		default:
+			return nil, fmt.Errorf("unknown join type: %s", joinType)
+		}
+
+		leftPrefix, err := core.ConfigString(config, "left_prefix", "")
+		if err != nil {
+			return nil, err
+		}
+		rightPrefix, err := core.ConfigString(config, "right_prefix", "right_")
+		if err != nil {
+			return nil, err
+		}
+
+		maxBuildRows, err := core.ConfigInt(config, "max_build_rows", 100000)
+		if err != nil {
+			return nil, err
+		}
+
+		return &JoinStep{
+			name:         name,
+			left:         core.InterpolateValue[[]any]{Raw: left},
+			right:        core.InterpolateValue[[]any]{Raw: right},
+			leftKeys:     leftKeys,
+			rightKeys:    rightKeys,
+			joinType:     joinType,
+			leftPrefix:   leftPrefix,
+			rightPrefix:  rightPrefix,
+			maxBuildRows: maxBuildRows,
+		}, nil
+	})
+}
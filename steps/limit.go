@@ -0,0 +1,74 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+)
+
+// LimitStep returns at most limit records of a list, skipping the first offset records.
+type LimitStep struct {
+	name   string
+	list   core.InterpolateValue[[]any]
+	limit  *core.InterpolateValue[int]
+	offset core.InterpolateValue[int]
+}
+
+func (l *LimitStep) Name() string { return l.name }
+
+func (l *LimitStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := l.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", l.list.Raw)
+	}
+
+	offset, err := l.offset.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("offset", l.offset.Raw)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+	}
+	start := min(offset, len(list))
+	end := len(list)
+
+	if l.limit != nil {
+		limit, err := l.limit.Resolve(state)
+		if err != nil {
+			return nil, core.ErrInterpolate("limit", l.limit.Raw)
+		}
+		if limit < 0 {
+			return nil, fmt.Errorf("limit must not be negative, got %d", limit)
+		}
+		end = min(start+limit, len(list))
+	}
+
+	return core.CreateDefaultResultData(list[start:end]), nil
+}
+
+func init() {
+	pipeline.RegisterStepType("limit", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		step := &LimitStep{
+			name:   name,
+			list:   core.InterpolateValue[[]any]{Raw: list},
+			offset: core.InterpolateValue[int]{Raw: 0},
+		}
+		if limit, ok := config["limit"]; ok {
+			step.limit = &core.InterpolateValue[int]{Raw: limit}
+		}
+		if offset, ok := config["offset"]; ok {
+			step.offset = core.InterpolateValue[int]{Raw: offset}
+		}
+		if step.limit == nil && config["offset"] == nil {
+			return nil, core.ErrMissingConfig("limit")
+		}
+
+		return step, nil
+	})
+}
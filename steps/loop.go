@@ -0,0 +1,167 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+)
+
+// LoopStep runs a sub-pipeline repeatedly, e.g. to read a paginated API.
+// Sub-steps read the iteration number as ctx.loop.iteration and the results
+// of the previous iteration as ctx.loop.previous.<step>.
+type LoopStep struct {
+	name          string
+	pipeline      *pipeline.Pipeline
+	while         *core.Expression
+	until         *core.Expression
+	maxIterations int
+	collectStep   string
+	collectOutput string
+	concat        bool
+}
+
+func (l *LoopStep) Name() string { return l.name }
+
+func (l *LoopStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	collected := []any{}
+	previous := map[string]any{}
+
+	stopped := false
+	iteration := 0
+	for ; iteration < l.maxIterations; iteration++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		vars := map[string]*core.Data{
+			"iteration": {Value: iteration},
+			"previous":  {Value: previous},
+		}
+
+		if l.while != nil {
+			check := state.Child()
+			check.Set("loop", vars)
+			ok, err := evalCondition(check, l.while)
+			if err != nil {
+				return nil, fmt.Errorf("while failed on iteration %d: %w", iteration, err)
+			}
+			if !ok {
+				stopped = true
+				break
+			}
+		}
+
+		subState, err := runSubPipeline(ctx, l.pipeline, state, "loop", vars)
+		if err != nil {
+			return nil, fmt.Errorf("loop iteration %d failed: %w", iteration, err)
+		}
+
+		if l.collectStep != "" {
+			if value, ok := getData(subState, l.collectStep, l.collectOutput); ok {
+				if list, isList := value.([]any); isList && l.concat {
+					collected = append(collected, list...)
+				} else if records, isRecords := core.RecordList(value); isRecords && l.concat {
+					for _, r := range records {
+						collected = append(collected, r)
+					}
+				} else {
+					collected = append(collected, value)
+				}
+			}
+		}
+		previous = l.results(subState)
+
+		if l.until != nil {
+			done, err := evalCondition(subState, l.until)
+			if err != nil {
+				return nil, fmt.Errorf("until failed on iteration %d: %w", iteration, err)
+			}
+			if done {
+				stopped = true
+				iteration++
+				break
+			}
+		}
+	}
+
+	if !stopped {
+		state.Logger.Warn("Loop stopped at max iterations", slog.String("step", l.name), slog.Int("max_iterations", l.maxIterations))
+	}
+
+	outputs := map[string]*core.Data{
+		"default":    {Value: iteration},
+		"iterations": {Value: iteration},
+	}
+	if l.collectStep != "" {
+		outputs["default"] = &core.Data{Value: collected}
+	}
+	return outputs, nil
+}
+
+// results returns the outputs of the sub-steps the way ctx shows them.
+func (l *LoopStep) results(subState *core.PipelineState) map[string]any {
+	results := make(map[string]any)
+	for _, name := range l.pipeline.StepNames() {
+		outputs, ok := subState.Outputs(name)
+		if !ok {
+			continue
+		}
+		results[name] = core.ContextValue(outputs)
+	}
+	return results
+}
+
+func evalCondition(state *core.PipelineState, expr *core.Expression) (bool, error) {
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return false, err
+	}
+	return ev.EvalBool(expr)
+}
+
+func init() {
+	pipeline.RegisterStepType("loop", func(name string, config map[string]any) (core.Step, error) {
+		subSteps, err := parseSubSteps(config)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := loadSubPipeline(subSteps)
+		if err != nil {
+			return nil, err
+		}
+
+		step := &LoopStep{name: name, pipeline: sub}
+
+		if _, ok := config["while"]; ok {
+			if step.while, err = compileExpression(config, "while"); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := config["until"]; ok {
+			if step.until, err = compileExpression(config, "until"); err != nil {
+				return nil, err
+			}
+		}
+
+		if step.maxIterations, err = core.ConfigInt(config, "max_iterations", 100); err != nil {
+			return nil, err
+		}
+		if step.maxIterations < 1 {
+			return nil, fmt.Errorf("'max_iterations' must be at least 1")
+		}
+
+		collect, err := core.ConfigString(config, "collect", "")
+		if err != nil {
+			return nil, err
+		}
+		step.collectStep, step.collectOutput = parseOutputRef(collect)
+
+		if step.concat, err = core.ConfigBool(config, "concat", false); err != nil {
+			return nil, err
+		}
+
+		return step, nil
+	})
+}
@@ -53,19 +53,12 @@ func init() {
 			if !ok {
 				return nil, fmt.Errorf("field map must contain a 'value' key, got %v", nameValue)
 			}
-			typeValue, ok := nameValue["type"].(string)
-			if !ok {
-				typeValue = "string" // Default type if not specified
+			typeValue, layout, err := parseTargetType(nameValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
 			}
 
-			switch typeValue {
-			case "int":
-				mapStepFields[name] = core.InterpolateValue[any]{Raw: value, TargetType: "int"}
-			case "bool":
-				mapStepFields[name] = core.InterpolateValue[any]{Raw: value, TargetType: "bool"}
-			default:
-				mapStepFields[name] = core.InterpolateValue[any]{Raw: value, TargetType: "string"}
-			}
+			mapStepFields[name] = core.InterpolateValue[any]{Raw: value, TargetType: typeValue, Layout: layout}
 		}
 		return &MapStep{name: name, fields: mapStepFields}, nil
 	})
@@ -0,0 +1,187 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PipelineStep calls the pipeline of another file like a function: params
+// are passed as ctx.params, and the outputs declared by the called pipeline
+// become the outputs of the step.
+type PipelineStep struct {
+	name   string
+	file   string
+	params map[string]core.InterpolateValue[any]
+}
+
+type loadedPipeline struct {
+	modTime  time.Time
+	config   pipeline.PipelineConfig
+	pipeline *pipeline.Pipeline
+	// calls counts the running calls, stale is set once the file changed. The
+	// connection pools of the pipeline are closed after its last call when stale.
+	calls int
+	stale bool
+}
+
+// pipelineFiles caches the called pipelines by path. A file is loaded again when it changes.
+// The connection pools of a called pipeline are shared by its calls and closed when the
+// top-level run ends.
+var pipelineFiles = struct {
+	sync.Mutex
+	loaded map[string]*loadedPipeline
+}{loaded: make(map[string]*loadedPipeline)}
+
+func (p *PipelineStep) Name() string { return p.name }
+
+func (p *PipelineStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	chain := pipeline.CallChain(ctx)
+
+	// Relative paths are resolved from the directory of the calling pipeline file
+	path := p.file
+	if !filepath.IsAbs(path) && len(chain) > 0 {
+		path = filepath.Join(filepath.Dir(chain[len(chain)-1]), path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(chain, path) {
+		return nil, fmt.Errorf("recursive pipeline call: %s", strings.Join(append(chain, path), " -> "))
+	}
+
+	called, err := loadPipelineFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer called.release()
+
+	params := make(map[string]any, len(called.config.Params)+len(p.params))
+	for name, value := range called.config.Params {
+		params[name] = value
+	}
+	for name, param := range p.params {
+		value, err := param.Resolve(state)
+		if err != nil {
+			return nil, core.ErrInterpolate(name, param.Raw)
+		}
+		params[name] = value
+	}
+
+	// The called pipeline doesn't see the results of the caller
+	subState := &core.PipelineState{Results: make(map[string]map[string]*core.Data), Logger: state.Logger}
+	if len(called.config.Connections) == 0 {
+		subState.Connections = state.Connections
+	}
+	subState.Set("params", core.CreateDefaultResultData(params))
+
+	ctx = pipeline.WithCall(ctx, path)
+	if err := called.pipeline.WithState(subState).Run(ctx, state.Logger); err != nil {
+		return nil, fmt.Errorf("pipeline %s failed: %w", p.file, err)
+	}
+
+	// Outputs that were not produced are left out, so that the steps reading them are skipped
+	outputs := make(map[string]*core.Data, len(called.config.Outputs))
+	for name, ref := range called.config.Outputs {
+		step, output := parseOutputRef(ref)
+		if value, ok := getData(subState, step, output); ok {
+			outputs[name] = &core.Data{Value: value}
+		}
+	}
+	if len(called.config.Outputs) == 0 {
+		outputs["default"] = &core.Data{}
+	}
+	return outputs, nil
+}
+
+func loadPipelineFile(path string) (*loadedPipeline, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipeline file: %w", err)
+	}
+
+	pipelineFiles.Lock()
+	defer pipelineFiles.Unlock()
+
+	previous, ok := pipelineFiles.loaded[path]
+	if ok && previous.modTime.Equal(info.ModTime()) {
+		previous.calls++
+		return previous, nil
+	}
+
+	config, err := pipeline.ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := pipeline.LoadPipeline(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pipeline %s: %w", path, err)
+	}
+
+	if previous != nil {
+		previous.stale = true
+		if previous.calls == 0 {
+			previous.pipeline.Close()
+		}
+	}
+	loaded := &loadedPipeline{modTime: info.ModTime(), config: config, pipeline: pl, calls: 1}
+	pipelineFiles.loaded[path] = loaded
+	return loaded, nil
+}
+
+// release ends a call of the pipeline.
+func (l *loadedPipeline) release() {
+	pipelineFiles.Lock()
+	defer pipelineFiles.Unlock()
+	l.calls--
+	if l.stale && l.calls == 0 {
+		l.pipeline.Close()
+	}
+}
+
+// closePipelineFiles empties the cache at the end of a top-level run. The pipelines
+// still called by an overlapping run are closed after their last call.
+func closePipelineFiles() {
+	pipelineFiles.Lock()
+	defer pipelineFiles.Unlock()
+	for path, l := range pipelineFiles.loaded {
+		delete(pipelineFiles.loaded, path)
+		l.stale = true
+		if l.calls == 0 {
+			l.pipeline.Close()
+		}
+	}
+}
+
+func init() {
+	pipeline.RegisterRunCleanup(closePipelineFiles)
+	pipeline.RegisterStepType("pipeline", func(name string, config map[string]any) (core.Step, error) {
+		file, ok := config["file"].(string)
+		if !ok {
+			return nil, core.ErrMissingConfig("file")
+		}
+
+		step := &PipelineStep{name: name, file: file}
+
+		if raw, ok := config["params"]; ok {
+			params, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("'params' must be a map, got %T", raw)
+			}
+			step.params = make(map[string]core.InterpolateValue[any], len(params))
+			for key, value := range params {
+				step.params[key] = core.InterpolateValue[any]{Raw: value}
+			}
+		}
+
+		return step, nil
+	})
+}
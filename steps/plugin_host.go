@@ -0,0 +1,338 @@
+package steps
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+
+	sdk "go-etl-sdk"
+)
+
+const (
+	// pluginIdleTimeout stops a persistent plugin that received no call for a while.
+	pluginIdleTimeout = time.Minute
+	// pluginStartTimeout bounds the handshake of a starting plugin.
+	pluginStartTimeout = 10 * time.Second
+	// pluginStopTimeout bounds the wait for a plugin asked to stop.
+	pluginStopTimeout = 5 * time.Second
+)
+
+// pluginHost runs the process of a persistent plugin, shared by every step
+// using the plugin. The process is started on the first call, restarted on
+// the next call after a crash, and stopped when idle.
+type pluginHost struct {
+	command string
+	mu      sync.Mutex
+	proc    *pluginProcess
+}
+
+// pluginProcess is one run of the plugin command.
+type pluginProcess struct {
+	host    *pluginHost
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pluginCall
+	idle    *time.Timer
+	done    chan struct{} // closed when the process exited
+	err     error
+}
+
+type pluginCall struct {
+	response chan *sdk.Message
+	logger   *slog.Logger
+}
+
+var pluginHosts = struct {
+	sync.Mutex
+	hosts map[string]*pluginHost
+}{hosts: make(map[string]*pluginHost)}
+
+// hostFor returns the host of the plugin command.
+func hostFor(command string) *pluginHost {
+	pluginHosts.Lock()
+	defer pluginHosts.Unlock()
+	host, ok := pluginHosts.hosts[command]
+	if !ok {
+		host = &pluginHost{command: command}
+		pluginHosts.hosts[command] = host
+	}
+	return host
+}
+
+// call runs the plugin with params and returns its result. Log lines of the
+// plugin are written to logger.
+func (h *pluginHost) call(ctx context.Context, logger *slog.Logger, params any) (json.RawMessage, error) {
+	h.mu.Lock()
+	p := h.proc
+	if p == nil || p.exited() {
+		var err error
+		if p, err = h.start(ctx); err != nil {
+			h.mu.Unlock()
+			return nil, err
+		}
+		h.proc = p
+	}
+	// The call is registered while holding the host lock, so that an idle
+	// shutdown cannot stop the process in between
+	id, pc := p.register(logger)
+	h.mu.Unlock()
+
+	defer p.release(id)
+	return p.request(ctx, id, pc, sdk.MethodRun, params)
+}
+
+// start starts the plugin process and performs the handshake.
+func (h *pluginHost) start(ctx context.Context) (*pluginProcess, error) {
+	cmd := exec.Command(h.command)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	// Lines written outside of a call cannot be attached to a step
+	cmd.Stderr = newPluginLogWriter(slog.Default().With("plugin", h.command))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", h.command, err)
+	}
+
+	p := &pluginProcess{
+		host:    h,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]*pluginCall),
+		done:    make(chan struct{}),
+	}
+	go p.read(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, pluginStartTimeout)
+	defer cancel()
+	id, pc := p.register(slog.Default())
+	raw, err := p.request(ctx, id, pc, sdk.MethodInitialize, sdk.InitializeParams{ProtocolVersion: sdk.ProtocolVersion})
+	p.release(id)
+	if err != nil {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", h.command, err)
+	}
+	var init sdk.InitializeResult
+	if err := json.Unmarshal(raw, &init); err != nil {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", h.command, err)
+	}
+	if init.ProtocolVersion != sdk.ProtocolVersion {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s speaks protocol version %d, expected %d", h.command, init.ProtocolVersion, sdk.ProtocolVersion)
+	}
+	slog.Debug("Plugin started", "command", h.command, "name", init.Name, "version", init.Version, "capabilities", init.Capabilities)
+	return p, nil
+}
+
+// stopIdle stops p if it still has no call.
+func (h *pluginHost) stopIdle(p *pluginProcess) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p.mu.Lock()
+	idle := len(p.pending) == 0
+	p.mu.Unlock()
+	if !idle || h.proc != p {
+		return
+	}
+	h.proc = nil
+	p.shutdown()
+}
+
+// StopPlugins stops the processes of the persistent plugins and waits for them
+// to exit, killing those still running after a while.
+func StopPlugins() {
+	pluginHosts.Lock()
+	defer pluginHosts.Unlock()
+	for _, h := range pluginHosts.hosts {
+		h.mu.Lock()
+		p := h.proc
+		h.proc = nil
+		h.mu.Unlock()
+		if p == nil {
+			continue
+		}
+
+		p.shutdown()
+		select {
+		case <-p.done:
+		case <-time.After(pluginStopTimeout):
+			p.kill()
+		}
+	}
+}
+
+func (p *pluginProcess) register(logger *slog.Logger) (int64, *pluginCall) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.idle != nil {
+		p.idle.Stop()
+	}
+	p.nextID++
+	pc := &pluginCall{response: make(chan *sdk.Message, 1), logger: logger}
+	p.pending[p.nextID] = pc
+	return p.nextID, pc
+}
+
+// release forgets the call id and arms the idle timer after the last call.
+func (p *pluginProcess) release(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+	if len(p.pending) > 0 {
+		return
+	}
+	if p.idle == nil {
+		p.idle = time.AfterFunc(pluginIdleTimeout, func() { p.host.stopIdle(p) })
+	} else {
+		p.idle.Reset(pluginIdleTimeout)
+	}
+}
+
+// request sends the request id and waits for its response. When ctx is done,
+// the plugin is asked to cancel the call.
+func (p *pluginProcess) request(ctx context.Context, id int64, pc *pluginCall, method string, params any) (json.RawMessage, error) {
+	if err := p.send(&id, method, params); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-pc.response:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-p.done:
+		return nil, fmt.Errorf("plugin exited: %w", p.err)
+	case <-ctx.Done():
+		p.send(nil, sdk.MethodCancel, sdk.CancelParams{ID: id})
+		return nil, ctx.Err()
+	}
+}
+
+func (p *pluginProcess) send(id *int64, method string, params any) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(sdk.Message{JSONRPC: "2.0", ID: id, Method: method, Params: b})
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	if _, err := p.stdin.Write(append(msg, '\n')); err != nil {
+		return fmt.Errorf("failed to write to plugin: %w", err)
+	}
+	return nil
+}
+
+// read dispatches the responses and log lines of the plugin until it exits.
+func (p *pluginProcess) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			p.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	p.err = p.cmd.Wait()
+	if p.err == nil {
+		p.err = errors.New("exit status 0")
+	}
+	p.mu.Lock()
+	crashed := len(p.pending) > 0
+	if p.idle != nil {
+		p.idle.Stop()
+	}
+	p.mu.Unlock()
+	if crashed {
+		slog.Warn("Plugin exited, it is restarted on the next call", "command", p.host.command, "error", p.err)
+	}
+	close(p.done)
+}
+
+func (p *pluginProcess) dispatch(line []byte) {
+	var msg sdk.Message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		slog.Warn("Invalid plugin message", "command", p.host.command, "error", err)
+		return
+	}
+
+	if msg.Method == sdk.MethodLog {
+		var params sdk.LogParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return
+		}
+		logger := slog.Default()
+		p.mu.Lock()
+		if pc, ok := p.pending[params.ID]; ok {
+			logger = pc.logger
+		}
+		p.mu.Unlock()
+		logPlugin(logger, params)
+		return
+	}
+
+	if msg.ID == nil {
+		if msg.Error != nil {
+			slog.Warn("Plugin error", "command", p.host.command, "error", msg.Error)
+		}
+		return
+	}
+	p.mu.Lock()
+	pc, ok := p.pending[*msg.ID]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	// A call reads a single response, a duplicate must not block the reader
+	select {
+	case pc.response <- &msg:
+	default:
+		slog.Warn("Duplicate plugin response", "command", p.host.command, "id", *msg.ID)
+	}
+}
+
+func (p *pluginProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdown asks the plugin to stop. Stdin is closed too, which stops the
+// plugins ignoring the request.
+func (p *pluginProcess) shutdown() {
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+	p.send(&id, sdk.MethodShutdown, nil)
+	p.stdin.Close()
+}
+
+func (p *pluginProcess) kill() {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	<-p.done
+}
@@ -0,0 +1,79 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	sdk "go-etl-sdk"
+)
+
+// pluginLogWriter writes the stderr lines of a plugin to a logger. Lines
+// written with sdk.Log keep their level and attributes, other lines are
+// logged as warnings.
+type pluginLogWriter struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	buf    []byte
+}
+
+func newPluginLogWriter(logger *slog.Logger) *pluginLogWriter {
+	return &pluginLogWriter{logger: logger}
+}
+
+func (w *pluginLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		logPluginLine(w.logger, w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs the last line when it does not end with a newline.
+func (w *pluginLogWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		logPluginLine(w.logger, w.buf)
+		w.buf = nil
+	}
+}
+
+func logPluginLine(logger *slog.Logger, line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	var params sdk.LogParams
+	if json.Unmarshal(line, &params) == nil && params.Message != "" {
+		logPlugin(logger, params)
+		return
+	}
+	logger.Warn(string(line), "stream", "stderr")
+}
+
+func logPlugin(logger *slog.Logger, params sdk.LogParams) {
+	level := slog.LevelInfo
+	switch params.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	args := make([]any, 0, 2*len(params.Attrs))
+	for key, value := range params.Attrs {
+		args = append(args, key, value)
+	}
+	logger.Log(context.Background(), level, params.Message, args...)
+}
@@ -0,0 +1,142 @@
+package steps
+
+import (
+	"fmt"
+	"go-etl/core"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	sdk "go-etl-sdk"
+)
+
+// validateManifest checks the input and output declarations of a plugin manifest.
+func validateManifest(manifest string, configuration sdk.Configuration) error {
+	for key, input := range configuration.Inputs {
+		if input.Type == "" {
+			return fmt.Errorf("%s: input '%s' has no type", manifest, key)
+		}
+		if !knownType(input.Type) {
+			return fmt.Errorf("%s: input '%s' has unknown type '%s'", manifest, key, input.Type)
+		}
+		if input.Default != nil {
+			if err := checkValue(input.Type, input, input.Default); err != nil {
+				return fmt.Errorf("%s: default of input '%s' %w", manifest, key, err)
+			}
+		}
+	}
+	for key, output := range configuration.Outputs {
+		if output.Type != "" && !knownType(output.Type) {
+			return fmt.Errorf("%s: output '%s' has unknown type '%s'", manifest, key, output.Type)
+		}
+	}
+	return nil
+}
+
+func knownType(t string) bool {
+	switch t {
+	case sdk.TypeText, sdk.TypeNumber, sdk.TypeInteger, sdk.TypeBoolean, sdk.TypeList, sdk.TypeObject, sdk.TypeConnection, sdk.TypeAny:
+		return true
+	}
+	return false
+}
+
+// checkValue checks value against the type and constraints of input. The
+// message completes "input 'name' ...", and does not show secret values.
+func checkValue(t string, input sdk.Input, value any) error {
+	show := func() string {
+		if input.Secret {
+			return "the secret value"
+		}
+		return fmt.Sprintf("%v", value)
+	}
+
+	var size float64
+	switch t {
+	case sdk.TypeText, sdk.TypeConnection:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be text, got %T", value)
+		}
+		size = float64(utf8.RuneCountInString(s))
+	case sdk.TypeNumber, sdk.TypeInteger:
+		f, ok := core.ToFloat(value)
+		if !ok {
+			return fmt.Errorf("must be a number, got %T", value)
+		}
+		if t == sdk.TypeInteger && f != math.Trunc(f) {
+			return fmt.Errorf("must be an integer, got %s", show())
+		}
+		size = f
+	case sdk.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean, got %T", value)
+		}
+	case sdk.TypeList:
+		list, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("must be a list, got %T", value)
+		}
+		size = float64(len(list))
+	case sdk.TypeObject:
+		if _, ok := value.(map[string]any); !ok {
+			return fmt.Errorf("must be an object, got %T", value)
+		}
+	}
+
+	if len(input.Enum) > 0 {
+		allowed := false
+		for _, option := range input.Enum {
+			if core.CompareValues(option, value) == 0 {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			options := make([]string, len(input.Enum))
+			for i, option := range input.Enum {
+				options[i] = fmt.Sprint(option)
+			}
+			return fmt.Errorf("must be one of %s, got %s", strings.Join(options, ", "), show())
+		}
+	}
+
+	bound := "be"
+	if t == sdk.TypeText || t == sdk.TypeConnection || t == sdk.TypeList {
+		bound = "have a length of"
+	}
+	if input.Min != nil && size < *input.Min {
+		return fmt.Errorf("must %s at least %v, got %s", bound, *input.Min, show())
+	}
+	if input.Max != nil && size > *input.Max {
+		return fmt.Errorf("must %s at most %v, got %s", bound, *input.Max, show())
+	}
+	return nil
+}
+
+// pluginOutputs maps the result of a plugin to the outputs declared by its
+// manifest, or returns it as the default output without declarations.
+func pluginOutputs(manifest string, configuration sdk.Configuration, result any) (map[string]*core.Data, error) {
+	if len(configuration.Outputs) == 0 {
+		return core.CreateDefaultResultData(result), nil
+	}
+
+	values, ok := result.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("plugin must return an object of the outputs declared in %s, got %T", manifest, result)
+	}
+	outputs := make(map[string]*core.Data, len(values))
+	for key, value := range values {
+		output, ok := configuration.Outputs[key]
+		if !ok {
+			return nil, fmt.Errorf("plugin returned output '%s' not declared in %s", key, manifest)
+		}
+		if output.Type != "" && value != nil {
+			if err := checkValue(output.Type, sdk.Input{}, value); err != nil {
+				return nil, fmt.Errorf("%s: output '%s' %w", manifest, key, err)
+			}
+		}
+		outputs[key] = &core.Data{Value: value}
+	}
+	return outputs, nil
+}
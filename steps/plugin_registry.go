@@ -0,0 +1,130 @@
+package steps
+
+import (
+	"errors"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	sdk "go-etl-sdk"
+)
+
+// PluginsEnv lists the plugin directories, separated like PATH.
+const PluginsEnv = "ETL_PLUGINS"
+
+// InstalledPlugin is a plugin found in the plugin search path.
+type InstalledPlugin struct {
+	Type          string            `json:"type"`
+	Command       string            `json:"command"`
+	Configuration sdk.Configuration `json:"configuration"`
+}
+
+var installedPlugins = struct {
+	sync.Mutex
+	plugins map[string]InstalledPlugin
+}{plugins: make(map[string]InstalledPlugin)}
+
+// LoadPlugins scans the plugin.json manifests of the subdirectories of dirs
+// and registers each plugin as a step type. Invalid plugins are reported in
+// the returned error; the valid ones are registered anyway.
+func LoadPlugins(dirs []string) error {
+	var errs []error
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin directory %s: %w", dir, err))
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			manifest := filepath.Join(dir, entry.Name(), "plugin.json")
+			if _, err := os.Stat(manifest); err != nil {
+				continue
+			}
+			if err := loadPlugin(manifest); err != nil {
+				errs = append(errs, fmt.Errorf("plugin %s: %w", manifest, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadPlugin(manifest string) error {
+	configuration, err := sdk.ReadConfiguration(manifest)
+	if err != nil {
+		return err
+	}
+	if err := validateManifest(manifest, configuration); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(manifest)
+	stepType := configuration.Type
+	if stepType == "" {
+		stepType = strings.TrimSuffix(filepath.Base(dir), "_plugin")
+	}
+	command := configuration.Command
+	if command == "" {
+		command = filepath.Base(dir)
+	}
+	if !filepath.IsAbs(command) {
+		command = filepath.Join(dir, command)
+	}
+	if command, err = filepath.Abs(command); err != nil {
+		return err
+	}
+	if _, err := os.Stat(command); err != nil {
+		return fmt.Errorf("plugin command: %w", err)
+	}
+
+	installedPlugins.Lock()
+	defer installedPlugins.Unlock()
+	if installed, ok := installedPlugins.plugins[stepType]; ok {
+		return fmt.Errorf("step type %s is already provided by %s", stepType, installed.Command)
+	}
+	if _, _, ok := pipeline.GetFactory(stepType); ok {
+		return fmt.Errorf("step type %s is already registered", stepType)
+	}
+
+	installedPlugins.plugins[stepType] = InstalledPlugin{Type: stepType, Command: command, Configuration: withoutSecrets(configuration)}
+	pipeline.RegisterStepType(stepType, func(name string, config map[string]any) (core.Step, error) {
+		return newPluginStep(name, command, manifest, configuration, config)
+	})
+	slog.Debug("Plugin registered", "type", stepType, "command", command)
+	return nil
+}
+
+// withoutSecrets returns a copy of configuration without the defaults of its
+// secret inputs, for listings.
+func withoutSecrets(configuration sdk.Configuration) sdk.Configuration {
+	inputs := make(map[string]sdk.Input, len(configuration.Inputs))
+	for name, input := range configuration.Inputs {
+		if input.Secret {
+			input.Default = nil
+		}
+		inputs[name] = input
+	}
+	configuration.Inputs = inputs
+	return configuration
+}
+
+// InstalledPlugins returns the plugins registered by LoadPlugins, by type. The
+// defaults of secret inputs are left out.
+func InstalledPlugins() []InstalledPlugin {
+	installedPlugins.Lock()
+	defer installedPlugins.Unlock()
+	plugins := make([]InstalledPlugin, 0, len(installedPlugins.plugins))
+	for _, plugin := range installedPlugins.plugins {
+		plugins = append(plugins, plugin)
+	}
+	slices.SortFunc(plugins, func(a, b InstalledPlugin) int { return strings.Compare(a.Type, b.Type) })
+	return plugins
+}
@@ -0,0 +1,66 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-etl/core"
+
+	sdk "go-etl-sdk"
+)
+
+// decodeResult decodes the stdout of an exec plugin: the sdk.Result envelope
+// written by the SDK, or the raw output of plugins predating it.
+func decodeResult(step string, out []byte) (any, error) {
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, &core.PluginError{Step: step, Code: "no_output", Message: "the plugin wrote no result on stdout"}
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(out, &fields) == nil && isEnvelope(fields) {
+		var result sdk.Result
+		if err := json.Unmarshal(out, &result); err != nil {
+			return nil, fmt.Errorf("invalid plugin result: %w", err)
+		}
+		if result.Version != sdk.ResultVersion {
+			return nil, fmt.Errorf("unsupported plugin result version %d, expected %d", result.Version, sdk.ResultVersion)
+		}
+		if !result.OK {
+			return nil, pluginError(step, result.Error)
+		}
+		return result.Outputs, nil
+	}
+
+	var result any
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("invalid plugin result: %w", err)
+	}
+	return result, nil
+}
+
+// isEnvelope reports whether the fields are those of an sdk.Result, which
+// carries its version.
+func isEnvelope(fields map[string]json.RawMessage) bool {
+	_, found := fields["etl_result"]
+	return found
+}
+
+func pluginError(step string, err *sdk.Error) error {
+	if err == nil {
+		return &core.PluginError{Step: step, Code: "plugin_error", Message: "the plugin failed without error"}
+	}
+	return &core.PluginError{Step: step, Code: err.Code, Message: err.Message, Retryable: err.Retryable}
+}
+
+// callError maps the error of a persistent plugin call to a plugin error.
+func callError(step string, err error) error {
+	var rpcErr *sdk.RPCError
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	if rpcErr.Data != nil {
+		return pluginError(step, rpcErr.Data)
+	}
+	return &core.PluginError{Step: step, Code: fmt.Sprintf("rpc_%d", -rpcErr.Code), Message: rpcErr.Message}
+}
@@ -0,0 +1,104 @@
+package steps
+
+import (
+	"fmt"
+	"go-etl/core"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldValue returns the value at a dotted path (e.g. "customer.name") of a record.
+func fieldValue(record any, path string) any {
+	value := record
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// compileExpression compiles the expression found under key of a step configuration.
+func compileExpression(config map[string]any, key string) (*core.Expression, error) {
+	raw, ok := config[key]
+	if !ok {
+		return nil, core.ErrMissingConfig(key)
+	}
+	source, ok := raw.(string)
+	if !ok {
+		source = fmt.Sprintf("%v", raw)
+	}
+	return core.CompileExpression(source)
+}
+
+// evalRecord evaluates expr with record and index bound to the evaluator.
+func evalRecord(ev *core.Evaluator, expr *core.Expression, record any, index int) (any, error) {
+	if err := bindRecord(ev, record, index); err != nil {
+		return nil, err
+	}
+	return ev.Eval(expr)
+}
+
+func bindRecord(ev *core.Evaluator, record any, index int) error {
+	if err := ev.Set("record", record); err != nil {
+		return err
+	}
+	return ev.Set("index", index)
+}
+
+// recordValue reads a value from a record, either from a field path or from an expression.
+type recordValue struct {
+	field      string
+	expression *core.Expression
+}
+
+// parseRecordValue reads a value definition made of a `field` path or a `value` expression.
+func parseRecordValue(config map[string]any) (recordValue, bool, error) {
+	if field, ok := config["field"].(string); ok {
+		return recordValue{field: field}, true, nil
+	}
+	if _, ok := config["value"]; ok {
+		expression, err := compileExpression(config, "value")
+		if err != nil {
+			return recordValue{}, false, err
+		}
+		return recordValue{expression: expression}, true, nil
+	}
+	return recordValue{}, false, nil
+}
+
+func (v recordValue) get(ev *core.Evaluator, record any, index int) (any, error) {
+	if v.expression == nil {
+		return fieldValue(record, v.field), nil
+	}
+	return evalRecord(ev, v.expression, record, index)
+}
+
+// keyString builds a comparable key from values, so that equal values of
+// different numeric types (e.g. int64 from a database and float64 from JSON) match.
+func keyString(values ...any) string {
+	var sb strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		switch v := value.(type) {
+		case nil:
+			sb.WriteString("n:")
+		case string:
+			sb.WriteString("s:" + v)
+		case time.Time:
+			sb.WriteString("t:" + v.Format(time.RFC3339Nano))
+		default:
+			if f, ok := core.ToFloat(v); ok {
+				sb.WriteString("f:" + strconv.FormatFloat(f, 'g', -1, 64))
+			} else {
+				fmt.Fprintf(&sb, "%T:%v", v, v)
+			}
+		}
+	}
+	return sb.String()
+}
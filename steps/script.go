@@ -0,0 +1,264 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"maps"
+	"runtime"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// memoryCheckInterval is how often the heap is sampled while a script with a memory limit runs.
+const memoryCheckInterval = 50 * time.Millisecond
+
+// ScriptStep runs a JavaScript program. Without a list, the value of the last
+// statement is the default output. With a list, the program must define
+// `function transform(record, index)`, which is called on every record.
+type ScriptStep struct {
+	name         string
+	program      *core.Expression
+	list         *core.InterpolateValue[[]any]
+	inputs       map[string]core.InterpolateValue[any]
+	timeout      time.Duration
+	maxMemory    uint64
+	maxCallStack int
+}
+
+func (s *ScriptStep) Name() string { return s.name }
+
+func (s *ScriptStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	var list []any
+	if s.list != nil {
+		var err error
+		if list, err = s.list.Resolve(state); err != nil {
+			return nil, core.ErrInterpolate("list", s.list.Raw)
+		}
+	}
+
+	inputs := make(map[string]any, len(s.inputs))
+	for name, input := range s.inputs {
+		value, err := input.Resolve(state)
+		if err != nil {
+			return nil, core.ErrInterpolate(name, input.Raw)
+		}
+		inputs[name] = value
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+	rt := ev.Runtime()
+	if s.maxCallStack > 0 {
+		rt.SetMaxCallStackSize(s.maxCallStack)
+	}
+
+	outputs := make(map[string]*core.Data)
+	emit := func(name string, value goja.Value) {
+		outputs[name] = &core.Data{Value: exportValue(value)}
+	}
+	if err := ev.Set("emit", emit); err != nil {
+		return nil, err
+	}
+	if err := ev.Set("inputs", inputs); err != nil {
+		return nil, err
+	}
+	if err := ev.Set("log", scriptLogger(state.Logger, s.name)); err != nil {
+		return nil, err
+	}
+
+	stop := s.watch(ctx, rt)
+	defer stop()
+
+	result, err := ev.Eval(s.program)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+
+	if s.list == nil {
+		if _, ok := outputs["default"]; !ok {
+			outputs["default"] = &core.Data{Value: result}
+		}
+		return outputs, nil
+	}
+
+	transform, ok := goja.AssertFunction(rt.Get("transform"))
+	if !ok {
+		return nil, fmt.Errorf("script must define function transform(record) when 'list' is set")
+	}
+
+	results := make([]any, 0, len(list))
+	for i, item := range list {
+		// Records are copied so the script can modify them without changing the input step results
+		if record, ok := item.(map[string]any); ok {
+			item = maps.Clone(record)
+		}
+		value, err := transform(goja.Undefined(), rt.ToValue(item), rt.ToValue(i))
+		if err != nil {
+			return nil, fmt.Errorf("transform failed on record %d: %w", i, scriptError(err))
+		}
+
+		// null drops the record, an array emits several records
+		switch v := exportValue(value).(type) {
+		case nil:
+		case []any:
+			results = append(results, v...)
+		default:
+			results = append(results, v)
+		}
+	}
+
+	if _, ok := outputs["default"]; !ok {
+		outputs["default"] = &core.Data{Value: results}
+	}
+	return outputs, nil
+}
+
+// watch interrupts the script when the step times out, the pipeline is
+// cancelled or the heap grows beyond the memory limit. The heap is shared by
+// the whole process, so the memory limit is approximate.
+func (s *ScriptStep) watch(ctx context.Context, rt *goja.Runtime) func() {
+	cancel := func() {}
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var ticker *time.Ticker
+		var tick <-chan time.Time
+		var baseline uint64
+		if s.maxMemory > 0 {
+			baseline = heapAlloc()
+			ticker = time.NewTicker(memoryCheckInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					rt.Interrupt(fmt.Sprintf("script exceeded timeout of %s", s.timeout))
+				} else {
+					rt.Interrupt("pipeline cancelled")
+				}
+				return
+			case <-tick:
+				if used := heapAlloc(); used > baseline && used-baseline > s.maxMemory {
+					rt.Interrupt(fmt.Sprintf("script exceeded memory limit of %d MB", s.maxMemory>>20))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		cancel()
+		rt.ClearInterrupt()
+	}
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// scriptError reports interruptions with their reason rather than the JavaScript stack.
+func scriptError(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Errorf("script interrupted: %v", interrupted.Value())
+	}
+	return err
+}
+
+// exportValue converts a JavaScript value to Go, keeping undefined and null as nil.
+func exportValue(value goja.Value) any {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	return value.Export()
+}
+
+// scriptLogger bridges log.debug/info/warn/error(message, attributes) to the pipeline logger.
+func scriptLogger(logger *slog.Logger, step string) map[string]any {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	level := func(level slog.Level) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			args := []any{slog.String("step", step)}
+			if attrs, ok := exportValue(call.Argument(1)).(map[string]any); ok {
+				for key, value := range attrs {
+					args = append(args, slog.Any(key, value))
+				}
+			}
+			logger.Log(context.Background(), level, call.Argument(0).String(), args...)
+			return goja.Undefined()
+		}
+	}
+	return map[string]any{
+		"debug": level(slog.LevelDebug),
+		"info":  level(slog.LevelInfo),
+		"warn":  level(slog.LevelWarn),
+		"error": level(slog.LevelError),
+	}
+}
+
+func init() {
+	pipeline.RegisterStepType("script", func(name string, config map[string]any) (core.Step, error) {
+		source, ok := config["script"].(string)
+		if !ok {
+			return nil, core.ErrMissingConfig("script")
+		}
+		program, err := core.CompileExpression(source)
+		if err != nil {
+			return nil, err
+		}
+
+		step := &ScriptStep{name: name, program: program}
+
+		if list, ok := config["list"]; ok {
+			step.list = &core.InterpolateValue[[]any]{Raw: list}
+		}
+
+		if raw, ok := config["inputs"]; ok {
+			inputs, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("'inputs' must be a map, got %T", raw)
+			}
+			step.inputs = make(map[string]core.InterpolateValue[any], len(inputs))
+			for key, value := range inputs {
+				step.inputs[key] = core.InterpolateValue[any]{Raw: value}
+			}
+		}
+
+		timeout, err := core.ConfigInt(config, "timeout_ms", 0)
+		if err != nil {
+			return nil, err
+		}
+		step.timeout = time.Duration(timeout) * time.Millisecond
+
+		maxMemory, err := core.ConfigInt(config, "max_memory_mb", 0)
+		if err != nil {
+			return nil, err
+		}
+		step.maxMemory = uint64(maxMemory) << 20
+
+		if step.maxCallStack, err = core.ConfigInt(config, "max_call_stack", 0); err != nil {
+			return nil, err
+		}
+
+		return step, nil
+	})
+}
@@ -0,0 +1,171 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"sort"
+)
+
+type sortKey struct {
+	field      string
+	expression *core.Expression
+	desc       bool
+	nullsFirst bool
+}
+
+// SortStep orders a record list by one or more keys. The sort is stable.
+type SortStep struct {
+	name string
+	list core.InterpolateValue[[]any]
+	keys []sortKey
+}
+
+func (s *SortStep) Name() string { return s.name }
+
+func (s *SortStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := s.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", s.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute every key once per record before sorting
+	values := make([][]any, len(list))
+	for i, record := range list {
+		values[i] = make([]any, len(s.keys))
+		for k, key := range s.keys {
+			if key.expression == nil {
+				values[i][k] = fieldValue(record, key.field)
+				continue
+			}
+			v, err := evalRecord(ev, key.expression, record, i)
+			if err != nil {
+				return nil, fmt.Errorf("sort key failed on record %d: %w", i, err)
+			}
+			values[i][k] = v
+		}
+	}
+
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return s.compare(values[order[a]], values[order[b]]) < 0
+	})
+
+	sorted := make([]any, len(list))
+	for i, idx := range order {
+		sorted[i] = list[idx]
+	}
+	return core.CreateDefaultResultData(sorted), nil
+}
+
+func (s *SortStep) compare(a, b []any) int {
+	for k, key := range s.keys {
+		va, vb := a[k], b[k]
+		if va == nil || vb == nil {
+			if va == nil && vb == nil {
+				continue
+			}
+			// Null placement does not depend on the sort direction
+			if (va == nil) == key.nullsFirst {
+				return -1
+			}
+			return 1
+		}
+
+		c := core.CompareValues(va, vb)
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func parseSortKeys(raw any) ([]sortKey, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'by' must be a list, got %T", raw)
+	}
+
+	keys := make([]sortKey, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			keys = append(keys, sortKey{field: v})
+		case map[string]any:
+			var key sortKey
+			var err error
+			if field, ok := v["field"].(string); ok {
+				key.field = field
+			} else if _, ok := v["key"]; ok {
+				if key.expression, err = compileExpression(v, "key"); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("sort key requires 'field' or 'key', got %v", v)
+			}
+
+			order, err := core.ConfigString(v, "order", "asc")
+			if err != nil {
+				return nil, err
+			}
+			switch order {
+			case "asc":
+			case "desc":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort order: %s", order)
+			}
+
+			nulls, err := core.ConfigString(v, "nulls", "last")
+			if err != nil {
+				return nil, err
+			}
+			switch nulls {
+			case "last":
+			case "first":
+				key.nullsFirst = true
+			default:
+				return nil, fmt.Errorf("invalid nulls ordering: %s", nulls)
+			}
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("invalid sort key %v", item)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("'by' requires at least one key")
+	}
+	return keys, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("sort", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		by, ok := config["by"]
+		if !ok {
+			return nil, core.ErrMissingConfig("by")
+		}
+		keys, err := parseSortKeys(by)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SortStep{name: name, list: core.InterpolateValue[[]any]{Raw: list}, keys: keys}, nil
+	})
+}
@@ -0,0 +1,28 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"go-etl/core"
+)
+
+// openDB returns the pool of the named connection when the pipeline declares one,
+// otherwise it opens a dedicated connection using connection as DSN.
+// The returned release function must be called once the step is done with the database.
+func openDB(state *core.PipelineState, driver, connection string) (*sql.DB, string, func(), error) {
+	if state != nil {
+		if _, ok := state.Connections.Lookup(connection); ok {
+			db, config, err := state.Connections.DB(connection)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			return db, config.Driver, func() {}, nil
+		}
+	}
+
+	db, err := sql.Open(driver, connection)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("open db error: %w", err)
+	}
+	return db, driver, func() { db.Close() }, nil
+}
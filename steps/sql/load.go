@@ -0,0 +1,445 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	LoadModeInsert   = "insert"
+	LoadModeUpsert   = "upsert"
+	LoadModeReplace  = "replace"
+	LoadModeTruncate = "truncate"
+)
+
+type columnMapping struct {
+	column string
+	field  string
+}
+
+// LoadStep writes a list of records into a table using batched transactions.
+type LoadStep struct {
+	name        string
+	driver      string
+	connection  string
+	table       string
+	records     core.InterpolateValue[[]any]
+	columns     []columnMapping
+	keys        []string
+	mode        string
+	batchSize   int
+	createTable bool
+}
+
+func (s *LoadStep) Name() string { return s.name }
+
+func (s *LoadStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := s.records.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("records", s.records.Raw)
+	}
+
+	records := make([]map[string]any, 0, len(list))
+	for i, item := range list {
+		record, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object, got %T", i, item)
+		}
+		records = append(records, record)
+	}
+
+	columns := s.columns
+	if len(columns) == 0 {
+		columns = inferColumns(records)
+	}
+	if len(columns) == 0 {
+		return core.CreateDefaultResultData(map[string]any{"inserted": 0, "updated": 0}), nil
+	}
+
+	db, driver, release, err := openDB(state, s.driver, s.connection)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if s.createTable {
+		if _, err := db.ExecContext(ctx, s.createTableQuery(columns, records)); err != nil {
+			return nil, fmt.Errorf("create table error: %w", err)
+		}
+	}
+
+	// Truncate mode replaces the rows at once: the delete and every batch share
+	// one transaction, so that a failing batch keeps the previous rows
+	var tx *sql.Tx
+	if s.mode == LoadModeTruncate {
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+quoteIdent(s.table)); err != nil {
+			return nil, fmt.Errorf("truncate error: %w", err)
+		}
+	}
+
+	var inserted, updated int64
+	for start := 0; start < len(records); start += s.batchSize {
+		end := min(start+s.batchSize, len(records))
+		ins, upd, err := s.loadBatch(ctx, db, tx, driver, columns, records[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("load batch starting at record %d: %w", start, err)
+		}
+		inserted += ins
+		updated += upd
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.CreateDefaultResultData(map[string]any{
+		"inserted": inserted,
+		"updated":  updated,
+	}), nil
+}
+
+// loadBatch writes records in their own transaction, or in shared when set.
+func (s *LoadStep) loadBatch(ctx context.Context, db *sql.DB, shared *sql.Tx, driver string, columns []columnMapping, records []map[string]any) (int64, int64, error) {
+	tx := shared
+	if tx == nil {
+		var err error
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return 0, 0, err
+		}
+		defer tx.Rollback()
+	}
+
+	insertStmt, err := tx.PrepareContext(ctx, s.insertQuery(driver, columns))
+	if err != nil {
+		return 0, 0, fmt.Errorf("prepare insert: %w", err)
+	}
+	defer insertStmt.Close()
+
+	var matchStmt *sql.Stmt
+	switch s.mode {
+	case LoadModeUpsert:
+		matchStmt, err = tx.PrepareContext(ctx, s.updateQuery(driver, columns))
+	case LoadModeReplace:
+		matchStmt, err = tx.PrepareContext(ctx, s.deleteQuery(driver))
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("prepare %s: %w", s.mode, err)
+	}
+	if matchStmt != nil {
+		defer matchStmt.Close()
+	}
+
+	var inserted, updated int64
+	for _, record := range records {
+		matched := false
+		if matchStmt != nil {
+			result, err := matchStmt.ExecContext(ctx, s.matchArgs(columns, record)...)
+			if err != nil {
+				return 0, 0, err
+			}
+			affected, _ := result.RowsAffected()
+			matched = affected > 0
+		}
+
+		if matched {
+			updated++
+			if s.mode == LoadModeUpsert {
+				continue
+			}
+		}
+
+		// In replace mode the matching row was deleted and is inserted again
+		if _, err := insertStmt.ExecContext(ctx, rowArgs(columns, record)...); err != nil {
+			return 0, 0, err
+		}
+		if !matched {
+			inserted++
+		}
+	}
+
+	if shared == nil {
+		if err := tx.Commit(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return inserted, updated, nil
+}
+
+func (s *LoadStep) insertQuery(driver string, columns []columnMapping) string {
+	names := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdent(c.column)
+		params[i] = placeholder(driver, i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(s.table), strings.Join(names, ", "), strings.Join(params, ", "))
+}
+
+func (s *LoadStep) updateQuery(driver string, columns []columnMapping) string {
+	var sets []string
+	n := 1
+	for _, c := range columns {
+		if slices.Contains(s.keys, c.column) {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", quoteIdent(c.column), placeholder(driver, n)))
+		n++
+	}
+	if len(sets) == 0 {
+		// Only key columns: a no-op update still reports whether the row exists
+		sets = append(sets, fmt.Sprintf("%s = %s", quoteIdent(s.keys[0]), quoteIdent(s.keys[0])))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdent(s.table), strings.Join(sets, ", "), s.keyCondition(driver, n))
+}
+
+func (s *LoadStep) deleteQuery(driver string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent(s.table), s.keyCondition(driver, 1))
+}
+
+func (s *LoadStep) keyCondition(driver string, firstParam int) string {
+	conditions := make([]string, len(s.keys))
+	for i, key := range s.keys {
+		conditions[i] = fmt.Sprintf("%s = %s", quoteIdent(key), placeholder(driver, firstParam+i))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// matchArgs returns the arguments of the update or delete statement:
+// non-key columns first (update only), then key columns.
+func (s *LoadStep) matchArgs(columns []columnMapping, record map[string]any) []any {
+	var args []any
+	if s.mode == LoadModeUpsert {
+		for _, c := range columns {
+			if !slices.Contains(s.keys, c.column) {
+				args = append(args, sqlValue(record[c.field]))
+			}
+		}
+	}
+	for _, key := range s.keys {
+		field := key
+		for _, c := range columns {
+			if c.column == key {
+				field = c.field
+				break
+			}
+		}
+		args = append(args, sqlValue(record[field]))
+	}
+	return args
+}
+
+func (s *LoadStep) createTableQuery(columns []columnMapping, records []map[string]any) string {
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = quoteIdent(c.column) + " " + inferColumnType(records, c.field)
+	}
+	if len(s.keys) > 0 {
+		keys := make([]string, len(s.keys))
+		for i, key := range s.keys {
+			keys[i] = quoteIdent(key)
+		}
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(s.table), strings.Join(defs, ", "))
+}
+
+func rowArgs(columns []columnMapping, record map[string]any) []any {
+	args := make([]any, len(columns))
+	for i, c := range columns {
+		args[i] = sqlValue(record[c.field])
+	}
+	return args
+}
+
+// sqlValue converts values the drivers cannot store natively (objects, lists) to JSON text.
+func sqlValue(value any) any {
+	switch value.(type) {
+	case map[string]any, []any:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(b)
+	default:
+		return value
+	}
+}
+
+func inferColumns(records []map[string]any) []columnMapping {
+	seen := make(map[string]bool)
+	var names []string
+	for _, record := range records {
+		for key := range record {
+			if !seen[key] {
+				seen[key] = true
+				names = append(names, key)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	columns := make([]columnMapping, len(names))
+	for i, name := range names {
+		columns[i] = columnMapping{column: name, field: name}
+	}
+	return columns
+}
+
+// inferColumnType picks a column type from the first non-null value of field.
+func inferColumnType(records []map[string]any, field string) string {
+	for _, record := range records {
+		switch record[field].(type) {
+		case nil:
+			continue
+		case int, int32, int64:
+			return "INTEGER"
+		case float32, float64:
+			return "REAL"
+		case bool:
+			return "BOOLEAN"
+		case time.Time:
+			return "TIMESTAMP"
+		default:
+			return "TEXT"
+		}
+	}
+	return "TEXT"
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func placeholder(driver string, n int) string {
+	switch driver {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", n)
+	case "sqlserver", "mssql":
+		return fmt.Sprintf("@p%d", n)
+	default:
+		return "?"
+	}
+}
+
+func parseColumns(raw any) ([]columnMapping, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		columns := make([]columnMapping, len(v))
+		for i, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("columns list must contain strings, got %T", item)
+			}
+			columns[i] = columnMapping{column: name, field: name}
+		}
+		return columns, nil
+	case map[string]any:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		columns := make([]columnMapping, len(names))
+		for i, name := range names {
+			field, ok := v[name].(string)
+			if !ok {
+				return nil, fmt.Errorf("column '%s' must map to a field name, got %T", name, v[name])
+			}
+			columns[i] = columnMapping{column: name, field: field}
+		}
+		return columns, nil
+	default:
+		return nil, fmt.Errorf("columns must be a list or a map, got %T", raw)
+	}
+}
+
+func init() {
+	pipeline.RegisterStepType("sql_load", func(name string, config map[string]any) (core.Step, error) {
+		connection, ok := config["connection"].(string)
+		if !ok {
+			return nil, core.ErrMissingConfig("connection")
+		}
+
+		table, ok := config["table"].(string)
+		if !ok {
+			return nil, core.ErrMissingConfig("table")
+		}
+
+		records, ok := config["records"]
+		if !ok {
+			return nil, core.ErrMissingConfig("records")
+		}
+
+		driver, err := core.ConfigString(config, "driver", "sqlite3")
+		if err != nil {
+			return nil, err
+		}
+
+		mode, err := core.ConfigString(config, "mode", LoadModeInsert)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := core.ConfigStringSlice(config, "keys")
+		if err != nil {
+			return nil, err
+		}
+
+		switch mode {
+		case LoadModeInsert, LoadModeTruncate:
+		case LoadModeUpsert, LoadModeReplace:
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("mode '%s' requires 'keys'", mode)
+			}
+		default:
+			return nil, fmt.Errorf("unknown load mode: %s", mode)
+		}
+
+		batchSize, err := core.ConfigInt(config, "batch_size", 500)
+		if err != nil {
+			return nil, err
+		}
+		if batchSize <= 0 {
+			return nil, fmt.Errorf("'batch_size' must be greater than zero")
+		}
+
+		createTable, err := core.ConfigBool(config, "create_table", false)
+		if err != nil {
+			return nil, err
+		}
+
+		columns, err := parseColumns(config["columns"])
+		if err != nil {
+			return nil, err
+		}
+
+		return &LoadStep{
+			name:        name,
+			driver:      driver,
+			connection:  connection,
+			table:       table,
+			records:     core.InterpolateValue[[]any]{Raw: records},
+			columns:     columns,
+			keys:        keys,
+			mode:        mode,
+			batchSize:   batchSize,
+			createTable: createTable,
+		}, nil
+	})
+}
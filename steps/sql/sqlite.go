@@ -2,7 +2,6 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"go-etl/core"
 	"go-etl/pipeline"
@@ -15,22 +14,32 @@ type SQLiteStep struct {
 	name       string
 	connection string `config:""`
 	query      string `config:""`
+	params     []core.InterpolateValue[any]
 }
 
 func (s *SQLiteStep) Name() string { return s.name }
 
 func (s *SQLiteStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
-	db, err := sql.Open("sqlite3", s.connection)
+	db, _, release, err := openDB(state, "sqlite3", s.connection)
 	if err != nil {
-		return nil, fmt.Errorf("open db error: %w", err)
+		return nil, err
+	}
+	defer release()
+
+	args := make([]any, len(s.params))
+	for i, param := range s.params {
+		value, err := param.Resolve(state)
+		if err != nil {
+			return nil, core.ErrInterpolate(fmt.Sprintf("params[%d]", i), param.Raw)
+		}
+		args[i] = value
 	}
-	defer db.Close()
 
 	// Check if this is a SELECT query or other operation
 	trimmed := strings.TrimSpace(strings.ToUpper(s.query))
 	if strings.HasPrefix(trimmed, "SELECT") {
 		// Handle SELECT queries
-		rows, err := db.Query(s.query)
+		rows, err := db.QueryContext(ctx, s.query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("query error: %w", err)
 		}
@@ -67,7 +76,7 @@ func (s *SQLiteStep) Run(ctx context.Context, state *core.PipelineState) (map[st
 		}, nil
 	} else {
 		// Handle DDL/DML operations (CREATE, INSERT, UPDATE, DELETE, etc.)
-		result, err := db.Exec(s.query)
+		result, err := db.ExecContext(ctx, s.query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("exec error: %w", err)
 		}
@@ -91,6 +100,15 @@ func init() {
 			return nil, core.ErrMissingConfig("query")
 		}
 
-		return &SQLiteStep{name: name, connection: connection, query: query}, nil
+		rawParams, err := core.ConfigList(config, "params")
+		if err != nil {
+			return nil, err
+		}
+		params := make([]core.InterpolateValue[any], len(rawParams))
+		for i, raw := range rawParams {
+			params[i] = core.InterpolateValue[any]{Raw: raw}
+		}
+
+		return &SQLiteStep{name: name, connection: connection, query: query, params: params}, nil
 	})
 }
@@ -0,0 +1,77 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseSubSteps reads the `steps` of a step running a sub-pipeline.
+func parseSubSteps(config map[string]any) ([]pipeline.StepConfig, error) {
+	stepsCfg, ok := config["steps"].([]any)
+	if !ok {
+		return nil, core.ErrMissingConfig("steps")
+	}
+
+	var subSteps []pipeline.StepConfig
+	for _, raw := range stepsCfg {
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid substep format")
+		}
+
+		subStepString, err := yaml.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal substep config: %v", err)
+		}
+
+		var subStep pipeline.StepConfig
+		if err := yaml.Unmarshal(subStepString, &subStep); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal substep config: %v", err)
+		}
+
+		subSteps = append(subSteps, subStep)
+	}
+	return subSteps, nil
+}
+
+// loadSubPipeline loads the sub-pipeline once, when the step is created.
+func loadSubPipeline(subSteps []pipeline.StepConfig) (*pipeline.Pipeline, error) {
+	sub, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: subSteps})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load substeps pipeline: %v", err)
+	}
+	return sub, nil
+}
+
+// runSubPipeline runs sub on a child of state where vars are the outputs of
+// the pseudo step name, e.g. ctx.foreach.item. It returns the child state
+// holding the results of the sub-steps.
+func runSubPipeline(ctx context.Context, sub *pipeline.Pipeline, state *core.PipelineState, name string, vars map[string]*core.Data) (*core.PipelineState, error) {
+	subState := state.Child()
+	subState.Set(name, vars)
+	err := sub.WithState(subState).Run(ctx, state.Logger)
+	return subState, err
+}
+
+// getData returns the value of an output of a step in state.
+func getData(state *core.PipelineState, step, output string) (any, bool) {
+	data, ok := state.Get(step, output)
+	if !ok {
+		return nil, false
+	}
+	return data.Value, true
+}
+
+// parseOutputRef reads a `step` or `step:output` reference, like pipeline inputs.
+func parseOutputRef(ref string) (string, string) {
+	step, output, ok := strings.Cut(ref, ":")
+	if !ok {
+		output = "default"
+	}
+	return step, output
+}
@@ -0,0 +1,171 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+)
+
+const (
+	SwitchRoute     = "route"
+	SwitchPartition = "partition"
+)
+
+type switchCase struct {
+	name      string
+	condition *core.Expression
+}
+
+// SwitchStep forwards its value on the output of the first matching case.
+// In partition mode every record of a list is routed to a case output instead.
+type SwitchStep struct {
+	name          string
+	value         core.InterpolateValue[any]
+	cases         []switchCase
+	defaultOutput string
+	mode          string
+}
+
+func (s *SwitchStep) Name() string { return s.name }
+
+func (s *SwitchStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	value, err := s.value.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("value", s.value.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.mode == SwitchRoute {
+		if err := ev.Set("value", value); err != nil {
+			return nil, err
+		}
+		output, err := s.match(ev)
+		if err != nil {
+			return nil, err
+		}
+		return core.CreateResultData(output, value), nil
+	}
+
+	list, ok := value.([]any)
+	if !ok {
+		records, isRecords := core.RecordList(value)
+		if !isRecords {
+			return nil, fmt.Errorf("partition requires a list, got %T", value)
+		}
+		list = make([]any, len(records))
+		for i, r := range records {
+			list[i] = r
+		}
+	}
+
+	// Every output exists, so that steps reading an empty partition still run
+	partitions := map[string][]any{s.defaultOutput: {}}
+	for _, c := range s.cases {
+		partitions[c.name] = []any{}
+	}
+	for i, record := range list {
+		if err := bindRecord(ev, record, i); err != nil {
+			return nil, err
+		}
+		output, err := s.match(ev)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		partitions[output] = append(partitions[output], record)
+	}
+
+	outputs := make(map[string]*core.Data, len(partitions))
+	for name, records := range partitions {
+		outputs[name] = &core.Data{Value: records}
+	}
+	return outputs, nil
+}
+
+// match returns the output of the first case whose condition is true.
+func (s *SwitchStep) match(ev *core.Evaluator) (string, error) {
+	for _, c := range s.cases {
+		ok, err := ev.EvalBool(c.condition)
+		if err != nil {
+			return "", fmt.Errorf("case '%s' failed: %w", c.name, err)
+		}
+		if ok {
+			return c.name, nil
+		}
+	}
+	return s.defaultOutput, nil
+}
+
+func parseSwitchCases(raw any, defaultOutput string) ([]switchCase, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'cases' must be a list, got %T", raw)
+	}
+
+	cases := make([]switchCase, 0, len(items))
+	seen := map[string]bool{defaultOutput: true}
+	for _, item := range items {
+		def, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("each case must be a map, got %T", item)
+		}
+		name, ok := def["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("case map must contain a 'name' key with a string value, got %T", def["name"])
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate case output: %s", name)
+		}
+		seen[name] = true
+
+		condition, err := compileExpression(def, "condition")
+		if err != nil {
+			return nil, fmt.Errorf("case '%s': %w", name, err)
+		}
+		cases = append(cases, switchCase{name: name, condition: condition})
+	}
+	return cases, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("switch", func(name string, config map[string]any) (core.Step, error) {
+		value, ok := config["value"]
+		if !ok {
+			return nil, core.ErrMissingConfig("value")
+		}
+
+		mode, err := core.ConfigString(config, "mode", SwitchRoute)
+		if err != nil {
+			return nil, err
+		}
+		if mode != SwitchRoute && mode != SwitchPartition {
+			return nil, fmt.Errorf("unknown switch mode: %s", mode)
+		}
+
+		defaultOutput, err := core.ConfigString(config, "default", "default")
+		if err != nil {
+			return nil, err
+		}
+
+		rawCases, ok := config["cases"]
+		if !ok {
+			return nil, core.ErrMissingConfig("cases")
+		}
+		cases, err := parseSwitchCases(rawCases, defaultOutput)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SwitchStep{
+			name:          name,
+			value:         core.InterpolateValue[any]{Raw: value},
+			cases:         cases,
+			defaultOutput: defaultOutput,
+			mode:          mode,
+		}, nil
+	})
+}
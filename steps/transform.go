@@ -0,0 +1,193 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+type transformField struct {
+	name       string
+	value      recordValue
+	hasValue   bool
+	targetType string
+	layout     string
+}
+
+// TransformStep maps every record of a list: fields are renamed, computed,
+// converted to their declared type, then dropped or kept.
+type TransformStep struct {
+	name   string
+	list   core.InterpolateValue[[]any]
+	rename map[string]string
+	fields []transformField
+	drop   []string
+	keep   []string
+}
+
+func (t *TransformStep) Name() string { return t.name }
+
+func (t *TransformStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := t.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", t.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]any, 0, len(list))
+	for i, item := range list {
+		record, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object, got %T", i, item)
+		}
+
+		out := maps.Clone(record)
+		for from, to := range t.rename {
+			if value, ok := out[from]; ok {
+				delete(out, from)
+				out[to] = value
+			}
+		}
+
+		// Expressions and field paths read the original record
+		for _, field := range t.fields {
+			value := out[field.name]
+			if field.hasValue {
+				if value, err = field.value.get(ev, record, i); err != nil {
+					return nil, fmt.Errorf("field '%s' failed on record %d: %w", field.name, i, err)
+				}
+			}
+			if value, err = core.Coerce(value, field.targetType, field.layout); err != nil {
+				return nil, fmt.Errorf("field '%s' on record %d: %w", field.name, i, err)
+			}
+			out[field.name] = value
+		}
+
+		for _, name := range t.drop {
+			delete(out, name)
+		}
+		if len(t.keep) > 0 {
+			maps.DeleteFunc(out, func(name string, _ any) bool { return !slices.Contains(t.keep, name) })
+		}
+		results = append(results, out)
+	}
+
+	return core.CreateDefaultResultData(results), nil
+}
+
+// parseTargetType reads the `type` of a field definition and its conversion
+// layout: `layout` for dates, `scale` for decimals.
+func parseTargetType(def map[string]any) (string, string, error) {
+	targetType, err := core.ConfigString(def, "type", "")
+	if err != nil {
+		return "", "", err
+	}
+	if err := core.ValidateType(targetType); err != nil {
+		return "", "", err
+	}
+
+	layout, err := core.ConfigString(def, "layout", "")
+	if err != nil {
+		return "", "", err
+	}
+	if _, ok := def["scale"]; ok {
+		scale, err := core.ConfigInt(def, "scale", 0)
+		if err != nil {
+			return "", "", err
+		}
+		layout = strconv.Itoa(scale)
+	}
+	return targetType, layout, nil
+}
+
+func parseTransformFields(raw any) ([]transformField, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'fields' must be a list, got %T", raw)
+	}
+
+	fields := make([]transformField, 0, len(items))
+	for _, item := range items {
+		def, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("each field must be a map, got %T", item)
+		}
+		name, ok := def["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("field map must contain a 'name' key with a string value, got %T", def["name"])
+		}
+
+		value, hasValue, err := parseRecordValue(def)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		targetType, layout, err := parseTargetType(def)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		if !hasValue && targetType == "" {
+			return nil, fmt.Errorf("field %s requires 'value', 'field' or 'type'", name)
+		}
+
+		fields = append(fields, transformField{
+			name:       name,
+			value:      value,
+			hasValue:   hasValue,
+			targetType: targetType,
+			layout:     layout,
+		})
+	}
+	return fields, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("transform", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		step := &TransformStep{name: name, list: core.InterpolateValue[[]any]{Raw: list}}
+
+		if raw, ok := config["rename"]; ok {
+			rename, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("'rename' must be a map, got %T", raw)
+			}
+			step.rename = make(map[string]string, len(rename))
+			for from, to := range rename {
+				s, ok := to.(string)
+				if !ok {
+					return nil, fmt.Errorf("rename of '%s' must be a string, got %T", from, to)
+				}
+				step.rename[from] = s
+			}
+		}
+
+		if raw, ok := config["fields"]; ok {
+			fields, err := parseTransformFields(raw)
+			if err != nil {
+				return nil, err
+			}
+			step.fields = fields
+		}
+
+		var err error
+		if step.drop, err = core.ConfigStringSlice(config, "drop"); err != nil {
+			return nil, err
+		}
+		if step.keep, err = core.ConfigStringSlice(config, "keep"); err != nil {
+			return nil, err
+		}
+
+		return step, nil
+	})
+}
@@ -0,0 +1,381 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type fieldRule struct {
+	field     string
+	types     []string
+	required  bool
+	nullable  bool
+	pattern   *regexp.Regexp
+	enum      []any
+	min       *float64
+	max       *float64
+	minLength *int
+	maxLength *int
+}
+
+type expressionRule struct {
+	name      string
+	condition *core.Expression
+	message   string
+}
+
+// ValidateStep checks records against field rules and expression rules, and
+// splits them into the valid and invalid outputs.
+type ValidateStep struct {
+	name        string
+	list        core.InterpolateValue[[]any]
+	fields      []fieldRule
+	rules       []expressionRule
+	errorsField string
+}
+
+func (v *ValidateStep) Name() string { return v.name }
+
+func (v *ValidateStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	list, err := v.list.Resolve(state)
+	if err != nil {
+		return nil, core.ErrInterpolate("list", v.list.Raw)
+	}
+
+	ev, err := core.NewEvaluator(state)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, invalid := []any{}, []any{}
+	for i, item := range list {
+		record, ok := item.(map[string]any)
+		if !ok {
+			invalid = append(invalid, map[string]any{
+				v.errorsField: []any{fmt.Sprintf("record is not an object, got %T", item)},
+			})
+			continue
+		}
+
+		var violations []any
+		for _, rule := range v.fields {
+			for _, msg := range rule.check(record) {
+				violations = append(violations, msg)
+			}
+		}
+
+		if len(v.rules) > 0 {
+			if err := bindRecord(ev, record, i); err != nil {
+				return nil, err
+			}
+			for _, rule := range v.rules {
+				ok, err := ev.EvalBool(rule.condition)
+				if err != nil {
+					return nil, fmt.Errorf("rule '%s' failed on record %d: %w", rule.name, i, err)
+				}
+				if !ok {
+					violations = append(violations, rule.message)
+				}
+			}
+		}
+
+		if len(violations) == 0 {
+			valid = append(valid, record)
+			continue
+		}
+		rejected := maps.Clone(record)
+		rejected[v.errorsField] = violations
+		invalid = append(invalid, rejected)
+	}
+
+	return map[string]*core.Data{
+		"valid":   {Value: valid},
+		"invalid": {Value: invalid},
+	}, nil
+}
+
+func (r fieldRule) check(record map[string]any) []string {
+	value, present := record[r.field]
+	if !present || value == nil {
+		if r.required && !(present && r.nullable) {
+			return []string{fmt.Sprintf("%s is required", r.field)}
+		}
+		return nil
+	}
+
+	if len(r.types) > 0 && !slices.ContainsFunc(r.types, func(t string) bool { return hasType(value, t) }) {
+		return []string{fmt.Sprintf("%s must be of type %s, got %T", r.field, strings.Join(r.types, " or "), value)}
+	}
+
+	var violations []string
+	if r.enum != nil && !slices.ContainsFunc(r.enum, func(e any) bool { return valuesEqual(e, value) }) {
+		violations = append(violations, fmt.Sprintf("%s must be one of %v, got %v", r.field, r.enum, value))
+	}
+
+	if s, ok := value.(string); ok {
+		if r.pattern != nil && !r.pattern.MatchString(s) {
+			violations = append(violations, fmt.Sprintf("%s does not match pattern %s", r.field, r.pattern))
+		}
+		length := len([]rune(s))
+		if r.minLength != nil && length < *r.minLength {
+			violations = append(violations, fmt.Sprintf("%s must be at least %d characters long", r.field, *r.minLength))
+		}
+		if r.maxLength != nil && length > *r.maxLength {
+			violations = append(violations, fmt.Sprintf("%s must be at most %d characters long", r.field, *r.maxLength))
+		}
+	}
+
+	if n, ok := core.ToFloat(value); ok {
+		if r.min != nil && n < *r.min {
+			violations = append(violations, fmt.Sprintf("%s must be >= %v", r.field, *r.min))
+		}
+		if r.max != nil && n > *r.max {
+			violations = append(violations, fmt.Sprintf("%s must be <= %v", r.field, *r.max))
+		}
+	}
+
+	return violations
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "any":
+		return true
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "int", "integer":
+		f, ok := core.ToFloat(value)
+		return ok && f == math.Trunc(f)
+	case "number", "float":
+		_, ok := core.ToFloat(value)
+		return ok
+	case "bool", "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	default:
+		return false
+	}
+}
+
+func parseFieldRules(raw any) ([]fieldRule, error) {
+	schema, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'schema' must be a map of fields, got %T", raw)
+	}
+
+	names := slices.Sorted(maps.Keys(schema))
+	rules := make([]fieldRule, 0, len(names))
+	for _, name := range names {
+		def, ok := schema[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema of field '%s' must be a map, got %T", name, schema[name])
+		}
+
+		rule := fieldRule{field: name}
+		var err error
+		if t, ok := def["type"].(string); ok {
+			if !hasKnownType(t) {
+				return nil, fmt.Errorf("field '%s': unknown type %s", name, t)
+			}
+			rule.types = []string{t}
+		}
+		if rule.required, err = core.ConfigBool(def, "required", false); err != nil {
+			return nil, fmt.Errorf("field '%s': %w", name, err)
+		}
+		if err := rule.parseConstraints(def, "pattern", "enum", "min", "max", "min_length", "max_length"); err != nil {
+			return nil, fmt.Errorf("field '%s': %w", name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseConstraints reads the constraint keys, named differently in the step
+// configuration and in JSON Schema.
+func (r *fieldRule) parseConstraints(def map[string]any, pattern, enum, min, max, minLength, maxLength string) error {
+	if p, ok := def[pattern].(string); ok {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.pattern = re
+	}
+	if e, ok := def[enum]; ok {
+		values, ok := e.([]any)
+		if !ok {
+			return fmt.Errorf("'%s' must be a list", enum)
+		}
+		r.enum = values
+	}
+	for key, target := range map[string]**float64{min: &r.min, max: &r.max} {
+		if raw, ok := def[key]; ok {
+			f, ok := core.ToFloat(raw)
+			if !ok {
+				return fmt.Errorf("'%s' must be a number", key)
+			}
+			*target = &f
+		}
+	}
+	for key, target := range map[string]**int{minLength: &r.minLength, maxLength: &r.maxLength} {
+		if _, ok := def[key]; ok {
+			n, err := core.ConfigInt(def, key, 0)
+			if err != nil {
+				return err
+			}
+			*target = &n
+		}
+	}
+	return nil
+}
+
+func hasKnownType(t string) bool {
+	switch t {
+	case "any", "string", "int", "integer", "number", "float", "bool", "boolean", "object", "array":
+		return true
+	default:
+		return false
+	}
+}
+
+// readJSONSchema converts the properties of an object JSON Schema into field rules.
+// Only the keywords type, required, pattern, enum, minimum, maximum, minLength
+// and maxLength are supported.
+func readJSONSchema(path string) ([]fieldRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var schema struct {
+		Properties map[string]map[string]any `json:"properties"`
+		Required   []string                  `json:"required"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %w", path, err)
+	}
+
+	names := slices.Sorted(maps.Keys(schema.Properties))
+	rules := make([]fieldRule, 0, len(names))
+	for _, name := range names {
+		def := schema.Properties[name]
+		rule := fieldRule{field: name, required: slices.Contains(schema.Required, name)}
+
+		var types []any
+		switch t := def["type"].(type) {
+		case string:
+			types = []any{t}
+		case []any:
+			types = t
+		}
+		for _, t := range types {
+			s, _ := t.(string)
+			switch {
+			case s == "null":
+				rule.nullable = true
+			case hasKnownType(s):
+				rule.types = append(rule.types, s)
+			default:
+				return nil, fmt.Errorf("%s: property '%s' has unsupported type %v", path, name, t)
+			}
+		}
+
+		if err := rule.parseConstraints(def, "pattern", "enum", "minimum", "maximum", "minLength", "maxLength"); err != nil {
+			return nil, fmt.Errorf("%s: property '%s': %w", path, name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseExpressionRules(raw any) ([]expressionRule, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'rules' must be a list, got %T", raw)
+	}
+
+	rules := make([]expressionRule, 0, len(items))
+	for i, item := range items {
+		def, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("each rule must be a map, got %T", item)
+		}
+		name, err := core.ConfigString(def, "name", fmt.Sprintf("rule%d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		condition, err := compileExpression(def, "condition")
+		if err != nil {
+			return nil, fmt.Errorf("rule '%s': %w", name, err)
+		}
+		message, err := core.ConfigString(def, "message", fmt.Sprintf("rule %s failed", name))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, expressionRule{name: name, condition: condition, message: message})
+	}
+	return rules, nil
+}
+
+func init() {
+	pipeline.RegisterStepType("validate", func(name string, config map[string]any) (core.Step, error) {
+		list, ok := config["list"]
+		if !ok {
+			return nil, core.ErrMissingConfig("list")
+		}
+
+		var fields []fieldRule
+		var err error
+		if schema, ok := config["schema"]; ok {
+			if fields, err = parseFieldRules(schema); err != nil {
+				return nil, err
+			}
+		}
+		if schemaFile, ok := config["schema_file"].(string); ok {
+			fileFields, err := readJSONSchema(schemaFile)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, fileFields...)
+		}
+
+		var rules []expressionRule
+		if rawRules, ok := config["rules"]; ok {
+			if rules, err = parseExpressionRules(rawRules); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(fields) == 0 && len(rules) == 0 {
+			return nil, fmt.Errorf("validate requires 'schema', 'schema_file' or 'rules'")
+		}
+
+		errorsField, err := core.ConfigString(config, "errors_field", "_errors")
+		if err != nil {
+			return nil, err
+		}
+
+		return &ValidateStep{
+			name:        name,
+			list:        core.InterpolateValue[[]any]{Raw: list},
+			fields:      fields,
+			rules:       rules,
+			errorsField: errorsField,
+		}, nil
+	})
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const DefaultPath = ".etl_state.db"
+
+// SQLiteStore keeps pipeline state as JSON values in a SQLite table.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("open state store: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS etl_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init state store: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Load returns the values whose key starts with prefix, with the prefix removed.
+func (s *SQLiteStore) Load(prefix string) (map[string]any, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM etl_state WHERE substr(key, 1, ?) = ?`, len(prefix), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("load state: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]any)
+	for rows.Next() {
+		var key, raw string
+		if err := rows.Scan(&key, &raw); err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("decode state '%s': %w", key, err)
+		}
+		values[strings.TrimPrefix(key, prefix)] = fromJSONNumber(value)
+	}
+	return values, rows.Err()
+}
+
+// Save writes all values in a single transaction.
+func (s *SQLiteStore) Save(values map[string]any) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO etl_state (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for key, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encode state '%s': %w", key, err)
+		}
+		if _, err := stmt.Exec(key, string(raw)); err != nil {
+			return fmt.Errorf("save state '%s': %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// fromJSONNumber keeps integers as int64 so numeric watermarks survive a round trip.
+func fromJSONNumber(value any) any {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
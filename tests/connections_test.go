@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNamedConnection(t *testing.T) {
+	t.Setenv("ETL_TEST_DSN", filepath.Join(t.TempDir(), "named.db"))
+
+	pl, err := pipeline.LoadPipeline(pipeline.PipelineConfig{
+		Connections: []pipeline.ConnectionConfig{
+			{Name: "warehouse", Driver: "sqlite3", DSNSecret: "env:ETL_TEST_DSN", MaxOpen: 1},
+		},
+		Steps: []pipeline.StepConfig{
+			{Name: "create", Type: "sqlite", Config: map[string]any{
+				"connection": "warehouse",
+				"query":      "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)",
+			}},
+			{Name: "load", Type: "sql_load", Inputs: []string{"create"}, Config: map[string]any{
+				"connection": "warehouse",
+				"table":      "items",
+				"records":    "[{id: 1, name: 'a'}, {id: 2, name: 'b'}]",
+			}},
+			{Name: "select", Type: "sqlite", Inputs: []string{"load"}, Config: map[string]any{
+				"connection": "warehouse",
+				"query":      "SELECT * FROM items",
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	var mu sync.Mutex
+	outputs := make(map[string]map[string]*core.Data)
+	pl.OnChange = func(event core.ChangeEvent) {
+		if event.Type == core.ChangeEventTypeEnd {
+			mu.Lock()
+			outputs[event.StepName] = event.Data
+			mu.Unlock()
+		}
+	}
+
+	if err := pl.Run(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Pipeline run failed: %v", err)
+	}
+
+	selected, ok := outputs["select"]
+	if !ok {
+		t.Fatal("Select step did not complete")
+	}
+	rows := selected["default"].Value.([]map[string]any)
+	if len(rows) != 2 {
+		t.Errorf("Expected 2 rows, got %v", rows)
+	}
+}
+
+func TestNamedConnectionMissingSecret(t *testing.T) {
+	_, err := pipeline.LoadPipeline(pipeline.PipelineConfig{
+		Connections: []pipeline.ConnectionConfig{
+			{Name: "warehouse", Driver: "sqlite3", DSNSecret: "env:ETL_TEST_UNSET_DSN"},
+		},
+	})
+	if err == nil {
+		t.Error("Expected error for missing secret")
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"go-etl/core"
+	"testing"
+)
+
+func evalContext(t *testing.T, results map[string]map[string]*core.Data, raw string) any {
+	t.Helper()
+	ev, err := core.NewEvaluator(&core.PipelineState{Results: results})
+	if err != nil {
+		t.Fatalf("Failed to create evaluator: %v", err)
+	}
+	expr, err := core.CompileExpression(raw)
+	if err != nil {
+		t.Fatalf("Failed to compile expression: %v", err)
+	}
+	value, err := ev.Eval(expr)
+	if err != nil {
+		t.Fatalf("Failed to evaluate %s: %v", raw, err)
+	}
+	return value
+}
+
+func TestContextNamedOutputs(t *testing.T) {
+	results := map[string]map[string]*core.Data{
+		"only":   core.CreateDefaultResultData(map[string]any{"id": 1}),
+		"scalar": {"default": {Value: 14}, "count": {Value: 2}},
+		"object": {"default": {Value: map[string]any{"total": 14, "count": 1}}, "count": {Value: 2}},
+		"diff":   {"added": {Value: []any{1}}, "removed": {Value: []any{}}},
+	}
+
+	cases := map[string]any{
+		"ctx.only.id":              int64(1),
+		"ctx.scalar.default":       int64(14),
+		"ctx.scalar.count":         int64(2),
+		"ctx.object.total":         int64(14),
+		"ctx.object.default.total": int64(14),
+		"ctx.diff.added.length":    int64(1),
+		"ctx.diff.default":         nil,
+		// A named output shadows the field of the default output with the same name
+		"ctx.object.count":         int64(2),
+		"ctx.object.default.count": int64(1),
+	}
+	for raw, expected := range cases {
+		if got := evalContext(t, results, raw); got != expected {
+			t.Errorf("%s: expected %v, got %v (%T)", raw, expected, got, got)
+		}
+	}
+
+	// The default output of the step is not modified
+	if _, ok := results["object"]["default"].Value.(map[string]any)["default"]; ok {
+		t.Error("Expected the default output to be left unchanged")
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func foreachState() *core.PipelineState {
+	return &core.PipelineState{
+		Logger: slog.Default(),
+		Results: map[string]map[string]*core.Data{
+			"input1":   core.CreateDefaultResultData([]any{1, 2, 3, 4, 5}),
+			"settings": core.CreateDefaultResultData(map[string]any{"factor": 10}),
+		},
+	}
+}
+
+func TestForeachCollectParallel(t *testing.T) {
+	step := newStep(t, "foreach", map[string]any{
+		"list":        "ctx.input1",
+		"parallelism": 3,
+		"collect":     "double",
+		"steps": []any{
+			map[string]any{"name": "double", "type": "script", "config": map[string]any{
+				"script": "ctx.foreach.item * ctx.settings.factor",
+			}},
+		},
+	})
+
+	result, err := step.Run(context.Background(), foreachState())
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+
+	expected := []any{int64(10), int64(20), int64(30), int64(40), int64(50)}
+	if got := result["default"].Value.([]any); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v in item order, got %v", expected, got)
+	}
+}
+
+func TestForeachContinueOnError(t *testing.T) {
+	step := newStep(t, "foreach", map[string]any{
+		"list":              "ctx.input1",
+		"collect":           "check",
+		"continue_on_error": true,
+		"steps": []any{
+			map[string]any{"name": "check", "type": "script", "config": map[string]any{
+				"script": "if (ctx.foreach.item % 2 == 0) { throw new Error('even'); } ctx.foreach.item",
+			}},
+		},
+	})
+
+	result, err := step.Run(context.Background(), foreachState())
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+
+	if got := result["default"].Value.([]any); !slices.Equal(got, []any{int64(1), int64(3), int64(5)}) {
+		t.Errorf("Expected the odd items, got %v", got)
+	}
+	errs := result["errors"].Value.([]any)
+	if got := fieldValues(errs, "index"); !slices.Equal(got, []any{1, 3}) {
+		t.Errorf("Expected errors for items 1 and 3, got %v", errs)
+	}
+}
+
+func TestForeachFailsFast(t *testing.T) {
+	step := newStep(t, "foreach", map[string]any{
+		"list": "ctx.input1",
+		"steps": []any{
+			map[string]any{"name": "check", "type": "script", "config": map[string]any{
+				"script": "if (ctx.foreach.item == 2) { throw new Error('boom'); }",
+			}},
+		},
+	})
+
+	if _, err := step.Run(context.Background(), foreachState()); err == nil {
+		t.Errorf("Expected the failing item to fail the step")
+	}
+}
@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"go-etl/pipeline"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runHTTPError runs an http client step expected to fail and returns its error.
+func runHTTPError(t *testing.T, config map[string]any) error {
+	t.Helper()
+	stepFactory, _ := pipeline.GetStepFactory("http client")
+	stepInstance, err := stepFactory("test_http", config)
+	if err != nil {
+		return err
+	}
+	_, err = stepInstance.Run(context.Background(), nil)
+	return err
+}
+
+// newAuthServer answers with the credentials of the request it received.
+func newAuthServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		json.NewEncoder(w).Encode(map[string]any{
+			"username":      username,
+			"password":      password,
+			"authorization": r.Header.Get("Authorization"),
+			"header":        r.Header.Get("X-API-Key"),
+			"query":         r.URL.Query().Get("api_key"),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClientAuth(t *testing.T) {
+	server := newAuthServer(t)
+	t.Setenv("ETL_TEST_TOKEN", "s3cret")
+
+	echo := runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"auth":   map[string]any{"type": "basic", "username": "etl", "password": "pa:ss"},
+	})
+	if echo["username"] != "etl" || echo["password"] != "pa:ss" {
+		t.Errorf("Unexpected basic auth %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"auth":   map[string]any{"type": "bearer", "token_secret": "env:ETL_TEST_TOKEN"},
+	})
+	if echo["authorization"] != "Bearer s3cret" {
+		t.Errorf("Unexpected bearer auth %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"auth":   map[string]any{"type": "api_key", "value_secret": "env:ETL_TEST_TOKEN"},
+	})
+	if echo["header"] != "s3cret" {
+		t.Errorf("Unexpected api key header %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"auth":   map[string]any{"type": "api_key", "in": "query", "name": "api_key", "value": "k1"},
+	})
+	if echo["query"] != "k1" {
+		t.Errorf("Unexpected api key query %v", echo)
+	}
+
+	err := runHTTPError(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"auth":   map[string]any{"type": "bearer", "token_secret": "env:ETL_TEST_MISSING"},
+	})
+	if err == nil {
+		t.Error("Expected an error for a missing secret")
+	}
+}
+
+func TestHTTPClientOAuth2(t *testing.T) {
+	var mu sync.Mutex
+	tokenRequests := 0
+	valid := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/token" {
+			clientID, clientSecret, _ := r.BasicAuth()
+			if clientID != "etl" || clientSecret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			tokenRequests++
+			valid = fmt.Sprintf("token-%d", tokenRequests)
+			json.NewEncoder(w).Encode(map[string]any{"access_token": valid, "expires_in": 3600, "scope": r.FormValue("scope")})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"authorization": r.Header.Get("Authorization")})
+	}))
+	defer server.Close()
+
+	config := map[string]any{
+		"url":    server.URL + "/orders",
+		"method": "GET",
+		"auth": map[string]any{
+			"type":          "oauth2",
+			"token_url":     server.URL + "/token",
+			"client_id":     "etl",
+			"client_secret": "secret",
+			"scopes":        []any{"orders.read"},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if echo := runHTTP(t, config); echo["authorization"] != "Bearer token-1" {
+			t.Errorf("Run %d: expected the cached token, got %v", i, echo)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected 1 token request, got %d", tokenRequests)
+	}
+
+	// A revoked token is renewed once the API rejects it
+	mu.Lock()
+	valid = "revoked"
+	mu.Unlock()
+	if echo := runHTTP(t, config); echo["authorization"] != "Bearer token-2" {
+		t.Errorf("Expected a renewed token, got %v", echo)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("Expected 2 token requests, got %d", tokenRequests)
+	}
+}
+
+func TestHTTPClientOAuth2SlowProvider(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "slow"})
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "fast"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"authorization": r.Header.Get("Authorization")})
+	}))
+	defer fast.Close()
+
+	oauth2 := func(server *httptest.Server) map[string]any {
+		return map[string]any{
+			"url":    server.URL + "/orders",
+			"method": "GET",
+			"auth": map[string]any{
+				"type":          "oauth2",
+				"token_url":     server.URL + "/token",
+				"client_id":     "etl",
+				"client_secret": "secret",
+			},
+		}
+	}
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		runHTTPError(t, oauth2(slow))
+	}()
+	<-started
+	defer func() {
+		close(release)
+		<-slowDone
+	}()
+
+	// A hanging token request must not hold the tokens of other providers
+	done := make(chan map[string]any, 1)
+	go func() { done <- runHTTP(t, oauth2(fast)) }()
+	select {
+	case echo := <-done:
+		if echo["authorization"] != "Bearer fast" {
+			t.Errorf("Unexpected authorization %v", echo)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Token request blocked by the token request of another provider")
+	}
+}
+
+func TestHTTPClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"tls": r.TLS != nil})
+	}))
+	defer server.Close()
+
+	if err := runHTTPError(t, map[string]any{"url": server.URL, "method": "GET"}); err == nil {
+		t.Error("Expected an error for an unknown certificate authority")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	echo := runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"tls":    map[string]any{"ca_file": caFile},
+	})
+	if echo["tls"] != true {
+		t.Errorf("Unexpected response %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "GET",
+		"tls":    map[string]any{"insecure_skip_verify": true},
+	})
+	if echo["tls"] != true {
+		t.Errorf("Unexpected response %v", echo)
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"url": r.URL.String()})
+	}))
+	defer proxy.Close()
+
+	echo := runHTTP(t, map[string]any{
+		"url":        "http://orders.example/orders/${ctx.order.id}",
+		"method":     "GET",
+		"proxy":      proxy.URL,
+		"timeout_ms": 5000,
+	})
+	if echo["url"] != "http://orders.example/orders/42" {
+		t.Errorf("Expected the request to go through the proxy, got %v", echo)
+	}
+}
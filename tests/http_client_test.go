@@ -2,78 +2,252 @@ package tests
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"go-etl/pipeline"
-	_ "go-etl/steps"
+	"go-etl/steps"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
+// newPostsServer serves /posts like the jsonplaceholder API.
+func newPostsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/posts/1":
+			json.NewEncoder(w).Encode(map[string]any{"id": 1, "userId": 1, "title": "first post"})
+		case r.Method == http.MethodPost && r.URL.Path == "/posts":
+			var post map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			post["id"] = 101
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(post)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestGet(t *testing.T) {
+	server := newPostsServer(t)
 	stepFactory, ok := pipeline.GetStepFactory("http client")
 	if !ok {
-		t.Errorf("Step type 'http client' not registered")
+		t.Fatalf("Step type 'http client' not registered")
 	}
 
 	stepInstance, err := stepFactory("testGet", map[string]any{
-		"url":      "https://jsonplaceholder.typicode.com/posts/1",
+		"url":      server.URL + "/posts/1",
 		"method":   "GET",
 		"headers":  map[string]string{"Accept": "application/json"},
 		"response": "json",
 	})
-
 	if err != nil {
-		t.Errorf("Failed to create step instance: %v", err)
-	}
-	if stepInstance == nil {
-		t.Error("Step instance is nil")
+		t.Fatalf("Failed to create step instance: %v", err)
 	}
 	if stepInstance.Name() != "testGet" {
 		t.Errorf("Expected step name 'testGet', got '%s'", stepInstance.Name())
 	}
 
-	ctx := context.Background()
-	result, err := stepInstance.Run(ctx, nil)
+	result, err := stepInstance.Run(context.Background(), nil)
 	if err != nil {
-		t.Errorf("Step execution failed: %v", err)
+		t.Fatalf("Step execution failed: %v", err)
 	}
-	if result == nil {
-		t.Error("Step execution returned nil result")
-	} else {
-		t.Logf("Step executed successfully, result: %v", result["default"].Value)
+	response := result["default"].Value.(*steps.HTTPClientResponse)
+	body := response.Body.(map[string]any)
+	if response.StatusCode != http.StatusOK || body["title"] != "first post" {
+		t.Errorf("Unexpected response %d %v", response.StatusCode, body)
 	}
 }
 
 func TestPost(t *testing.T) {
+	server := newPostsServer(t)
 	stepFactory, ok := pipeline.GetStepFactory("http client")
 	if !ok {
-		t.Errorf("Step type 'http client' not registered")
+		t.Fatalf("Step type 'http client' not registered")
 	}
 
 	stepInstance, err := stepFactory("testPost", map[string]any{
-		"url":      "https://jsonplaceholder.typicode.com/posts",
+		"url":      server.URL + "/posts",
 		"method":   "POST",
 		"headers":  map[string]string{"Content-Type": "application/json"},
 		"body":     map[string]any{"title": "foo", "body": "bar", "userId": 1},
 		"response": "json",
 	})
-
 	if err != nil {
-		t.Errorf("Failed to create step instance: %v", err)
-	}
-	if stepInstance == nil {
-		t.Error("Step instance is nil")
+		t.Fatalf("Failed to create step instance: %v", err)
 	}
 	if stepInstance.Name() != "testPost" {
 		t.Errorf("Expected step name 'testPost', got '%s'", stepInstance.Name())
 	}
 
-	ctx := context.Background()
-	result, err := stepInstance.Run(ctx, nil)
+	result, err := stepInstance.Run(context.Background(), nil)
 	if err != nil {
-		t.Errorf("Step execution failed: %v", err)
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	response := result["default"].Value.(*steps.HTTPClientResponse)
+	body := response.Body.(map[string]any)
+	if response.StatusCode != http.StatusCreated || body["id"] != float64(101) || body["title"] != "foo" {
+		t.Errorf("Unexpected response %d %v", response.StatusCode, body)
+	}
+}
+
+// newEchoServer returns a server answering with a description of the request it received.
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		echo := map[string]any{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"query":       r.URL.Query(),
+			"header":      r.Header.Get("X-Tenant"),
+			"contentType": r.Header.Get("Content-Type"),
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			if err := r.ParseMultipartForm(1 << 20); err == nil {
+				file, header, _ := r.FormFile("upload")
+				content, _ := io.ReadAll(file)
+				echo["form"] = map[string]any{"name": r.FormValue("name"), "filename": header.Filename, "file": string(content)}
+			}
+		} else {
+			body, _ := io.ReadAll(r.Body)
+			echo["body"] = string(body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(echo)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func runHTTP(t *testing.T, config map[string]any) map[string]any {
+	t.Helper()
+	result := runStepWith(t, "http client", config, map[string]any{
+		"order": map[string]any{"id": 42, "tenant": "acme", "lines": []any{map[string]any{"sku": "a"}}},
+	})
+	return result["default"].Value.(*steps.HTTPClientResponse).Body.(map[string]any)
+}
+
+func TestHTTPClientTemplates(t *testing.T) {
+	server := newEchoServer(t)
+	echo := runHTTP(t, map[string]any{
+		"url":     server.URL + "/orders/${ctx.order.id}",
+		"method":  "GET",
+		"headers": map[string]any{"X-Tenant": "${ctx.order.tenant}"},
+		"query":   map[string]any{"expand": []any{"lines", "customer"}, "tenant": "${ctx.order.tenant}"},
+	})
+
+	if echo["path"] != "/orders/42" {
+		t.Errorf("Expected path /orders/42, got %v", echo["path"])
+	}
+	if echo["header"] != "acme" {
+		t.Errorf("Expected header acme, got %v", echo["header"])
+	}
+	query := echo["query"].(map[string]any)
+	if fmt.Sprint(query["expand"]) != "[lines customer]" || fmt.Sprint(query["tenant"]) != "[acme]" {
+		t.Errorf("Unexpected query %v", query)
+	}
+}
+
+func TestHTTPClientBodies(t *testing.T) {
+	server := newEchoServer(t)
+
+	echo := runHTTP(t, map[string]any{
+		"url":    server.URL,
+		"method": "POST",
+		"body":   map[string]any{"id": "${ctx.order.id}", "lines": "${ctx.order.lines}", "note": "order ${ctx.order.id}"},
+	})
+	if echo["contentType"] != "application/json" || echo["body"] != `{"id":42,"lines":[{"sku":"a"}],"note":"order 42"}` {
+		t.Errorf("Unexpected JSON request %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":       server.URL,
+		"method":    "POST",
+		"body_type": "form",
+		"body":      map[string]any{"id": "${ctx.order.id}", "tag": []any{"a", "b"}},
+	})
+	if echo["contentType"] != "application/x-www-form-urlencoded" || echo["body"] != "id=42&tag=a&tag=b" {
+		t.Errorf("Unexpected form request %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":       server.URL,
+		"method":    "PUT",
+		"body_type": "text",
+		"body":      "order ${ctx.order.id} for `${ctx.order.tenant}`",
+	})
+	if echo["body"] != "order 42 for `acme`" {
+		t.Errorf("Unexpected text request %v", echo)
+	}
+
+	path := filepath.Join(t.TempDir(), "orders.csv")
+	writeFile(t, path, "id\n42\n")
+	echo = runHTTP(t, map[string]any{
+		"url":       server.URL,
+		"method":    "POST",
+		"body_type": "multipart",
+		"body":      map[string]any{"name": "${ctx.order.tenant}", "upload": map[string]any{"file": path}},
+	})
+	form, _ := echo["form"].(map[string]any)
+	if form["name"] != "acme" || form["filename"] != "orders.csv" || form["file"] != "id\n42\n" {
+		t.Errorf("Unexpected multipart request %v", echo)
+	}
+
+	echo = runHTTP(t, map[string]any{
+		"url":       server.URL,
+		"method":    "POST",
+		"body_type": "binary",
+		"body":      map[string]any{"file": path},
+	})
+	if echo["contentType"] != "application/octet-stream" || echo["body"] != "id\n42\n" {
+		t.Errorf("Unexpected binary request %v", echo)
+	}
+}
+
+func TestBinaryBodyClosedWhenNotSent(t *testing.T) {
+	if _, err := os.ReadDir("/proc/self/fd"); err != nil {
+		t.Skip("open files cannot be counted on this system")
+	}
+	openFiles := func() int {
+		entries, _ := os.ReadDir("/proc/self/fd")
+		return len(entries)
+	}
+
+	path := filepath.Join(t.TempDir(), "orders.csv")
+	writeFile(t, path, "id\n42\n")
+	config := map[string]any{
+		"url":       "http://127.0.0.1:1/orders",
+		"method":    "POST",
+		"body_type": "binary",
+		"body":      map[string]any{"file": path},
+		// The token cannot be fetched, so the request is never sent
+		"auth": map[string]any{
+			"type":          "oauth2",
+			"token_url":     "http://127.0.0.1:1/token",
+			"client_id":     "etl",
+			"client_secret": "secret",
+		},
+	}
+
+	before := openFiles()
+	for i := 0; i < 20; i++ {
+		if err := runHTTPError(t, config); err == nil {
+			t.Fatal("Expected an authentication error")
+		}
 	}
-	if result == nil {
-		t.Error("Step execution returned nil result")
-	} else {
-		t.Logf("Step executed successfully, result: %v", result["default"].Value)
+	if after := openFiles(); after-before >= 10 {
+		t.Errorf("Expected the body files to be closed, open files went from %d to %d", before, after)
 	}
 }
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-etl/steps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// newPagedServer serves the numbers 1 to 7 three at a time with every pagination style.
+func newPagedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	const total, size = 7, 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		start := 0
+		switch r.URL.Path {
+		case "/page":
+			page, _ := strconv.Atoi(query.Get("page"))
+			start = (page - 1) * size
+		case "/offset":
+			start, _ = strconv.Atoi(query.Get("offset"))
+		default:
+			start, _ = strconv.Atoi(query.Get("cursor"))
+		}
+
+		data := []int{}
+		for i := start; i < min(start+size, total); i++ {
+			data = append(data, i+1)
+		}
+		body := map[string]any{"data": data}
+
+		if end := start + size; end < total {
+			switch r.URL.Path {
+			case "/cursor":
+				body["next"] = end
+			case "/next":
+				body["links"] = map[string]any{"next": fmt.Sprintf("/next?cursor=%d", end)}
+			case "/link":
+				w.Header().Set("Link", fmt.Sprintf(`</link?cursor=%d>; rel="next", </link?cursor=0>; rel="first"`, end))
+			}
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClientPagination(t *testing.T) {
+	server := newPagedServer(t)
+	expected := []any{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0}
+
+	tests := map[string]map[string]any{
+		"/page":   {"type": "page", "size_param": "per_page", "size": 3},
+		"/offset": {"type": "offset", "size": 3},
+		"/cursor": {"type": "cursor", "path": "next"},
+		"/next":   {"type": "next_url", "path": "links.next"},
+		"/link":   {"type": "link"},
+	}
+	for path, pagination := range tests {
+		pagination["items"] = "data"
+		result := runStep(t, "http client", map[string]any{
+			"url":        server.URL + path,
+			"method":     "GET",
+			"pagination": pagination,
+		}, nil)
+
+		if got := result["default"].Value.([]any); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", path, expected, got)
+		}
+		if result["pages"].Value != 3 {
+			t.Errorf("%s: expected 3 pages, got %v", path, result["pages"].Value)
+		}
+	}
+}
+
+func TestHTTPClientPaginationMaxPages(t *testing.T) {
+	server := newPagedServer(t)
+	result := runStep(t, "http client", map[string]any{
+		"url":        server.URL + "/cursor",
+		"method":     "GET",
+		"pagination": map[string]any{"type": "cursor", "path": "next", "items": "data", "max_pages": 2, "delay_ms": 1},
+	}, nil)
+
+	if got := result["default"].Value.([]any); len(got) != 6 {
+		t.Errorf("Expected the items of 2 pages, got %v", got)
+	}
+}
+
+func TestHTTPClientPaginationPageError(t *testing.T) {
+	server := newPagedServer(t)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "3" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "database unavailable"}`))
+			return
+		}
+		http.Redirect(w, r, server.URL+r.URL.String(), http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(failing.Close)
+
+	result := runStep(t, "http client", map[string]any{
+		"url":        failing.URL + "/cursor",
+		"method":     "GET",
+		"pagination": map[string]any{"type": "cursor", "path": "next", "items": "data"},
+	}, nil)
+
+	if _, ok := result["default"]; ok {
+		t.Errorf("Expected no default output, got %v", result["default"].Value)
+	}
+	errorData, ok := result["error"]
+	if !ok {
+		t.Fatalf("Expected the error output, got %v", result)
+	}
+	res := errorData.Value.(*steps.HTTPClientResponse)
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", res.StatusCode)
+	}
+	if body, _ := res.Body.(map[string]any); body["message"] != "database unavailable" {
+		t.Errorf("Expected the decoded body of the failed page, got %v", res.Body)
+	}
+}
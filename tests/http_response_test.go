@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"go-etl/steps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newResponseServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`[{"id":1},{"id":2}]`))
+		case "/text":
+			w.Write([]byte("plain text"))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"order not found"}`))
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func runResponse(t *testing.T, config map[string]any) (*steps.HTTPClientResponse, *steps.HTTPClientResponse) {
+	t.Helper()
+	result := runStepWith(t, "http client", config, map[string]any{"order": map[string]any{"id": 42}})
+	var res, errRes *steps.HTTPClientResponse
+	if data, ok := result["default"]; ok {
+		res = data.Value.(*steps.HTTPClientResponse)
+	}
+	if data, ok := result["error"]; ok {
+		errRes = data.Value.(*steps.HTTPClientResponse)
+	}
+	return res, errRes
+}
+
+func TestHTTPClientResponseModes(t *testing.T) {
+	server := newResponseServer(t)
+
+	res, _ := runResponse(t, map[string]any{"url": server.URL + "/list", "method": "GET"})
+	if list, ok := res.Body.([]any); !ok || len(list) != 2 {
+		t.Errorf("Expected a JSON list, got %v", res.Body)
+	}
+	if len(res.HeaderValues["Set-Cookie"]) != 2 || res.Headers["Set-Cookie"] != "a=1" {
+		t.Errorf("Expected both header values, got %v", res.HeaderValues)
+	}
+
+	res, _ = runResponse(t, map[string]any{"url": server.URL + "/empty", "method": "GET"})
+	if res.StatusCode != http.StatusNoContent || res.Body != nil {
+		t.Errorf("Expected an empty body, got %d %v", res.StatusCode, res.Body)
+	}
+
+	res, _ = runResponse(t, map[string]any{"url": server.URL + "/text", "method": "GET", "response": "text"})
+	if res.Body != "plain text" {
+		t.Errorf("Expected text, got %v", res.Body)
+	}
+
+	res, _ = runResponse(t, map[string]any{"url": server.URL + "/text", "method": "GET", "response": "bytes"})
+	if b, ok := res.Body.([]byte); !ok || string(b) != "plain text" {
+		t.Errorf("Expected bytes, got %v", res.Body)
+	}
+
+	dir := t.TempDir()
+	res, _ = runResponse(t, map[string]any{
+		"url":           server.URL + "/text",
+		"method":        "GET",
+		"response":      "file",
+		"response_file": filepath.Join(dir, "orders", "${ctx.order.id}.txt"),
+	})
+	path := filepath.Join(dir, "orders", "42.txt")
+	content, err := os.ReadFile(path)
+	if res.Body != path || err != nil || string(content) != "plain text" {
+		t.Errorf("Expected the body in %s, got %v %q %v", path, res.Body, content, err)
+	}
+}
+
+func TestHTTPClientErrorOutput(t *testing.T) {
+	server := newResponseServer(t)
+
+	res, errRes := runResponse(t, map[string]any{"url": server.URL + "/missing", "method": "GET"})
+	if res != nil || errRes == nil {
+		t.Fatalf("Expected only the error output, got %v %v", res, errRes)
+	}
+	body, _ := errRes.Body.(map[string]any)
+	if errRes.StatusCode != http.StatusNotFound || body["message"] != "order not found" {
+		t.Errorf("Unexpected error output %d %v", errRes.StatusCode, errRes.Body)
+	}
+
+	_, errRes = runResponse(t, map[string]any{"url": server.URL + "/down", "method": "GET"})
+	if errRes == nil || errRes.Body != "<html>bad gateway</html>" {
+		t.Errorf("Expected the error body as text, got %v", errRes)
+	}
+
+	res, errRes = runResponse(t, map[string]any{
+		"url":           server.URL + "/missing",
+		"method":        "GET",
+		"accept_status": []any{"2xx", 404},
+	})
+	if res == nil || errRes != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 to be accepted, got %v %v", res, errRes)
+	}
+}
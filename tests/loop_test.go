@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+)
+
+// loopInputs holds the settings read by the loop steps.
+var loopInputs = map[string]any{"settings": map[string]any{"pages": 3}}
+
+// page simulates a paginated API returning two items per page and the next cursor.
+var pageStep = map[string]any{"name": "page", "type": "script", "config": map[string]any{
+	"script": `
+		const cursor = ctx.loop.previous.page ? ctx.loop.previous.page.next : 0;
+		emit("next", cursor + 1 < ctx.settings.pages ? cursor + 1 : null);
+		[cursor * 2, cursor * 2 + 1];
+	`,
+}}
+
+func TestLoopUntil(t *testing.T) {
+	result := runStepWith(t, "loop", map[string]any{
+		"until":   "ctx.page.next == null",
+		"collect": "page",
+		"concat":  true,
+		"steps":   []any{pageStep},
+	}, loopInputs)
+
+	expected := []any{int64(0), int64(1), int64(2), int64(3), int64(4), int64(5)}
+	if got := result["default"].Value.([]any); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if result["iterations"].Value != 3 {
+		t.Errorf("Expected 3 iterations, got %v", result["iterations"].Value)
+	}
+}
+
+func TestLoopWhileAndMaxIterations(t *testing.T) {
+	result := runStepWith(t, "loop", map[string]any{
+		"while":   "ctx.loop.iteration < 10",
+		"collect": "square",
+		"steps": []any{
+			map[string]any{"name": "square", "type": "script", "config": map[string]any{
+				"script": "ctx.loop.iteration * ctx.loop.iteration",
+			}},
+		},
+		"max_iterations": 4,
+	}, loopInputs)
+
+	expected := []any{int64(0), int64(1), int64(4), int64(9)}
+	if got := result["default"].Value.([]any); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
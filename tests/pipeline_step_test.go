@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func runPipelineFile(t *testing.T, path string) (map[string]map[string]*core.Data, error) {
+	t.Helper()
+	pl, err := pipeline.LoadPipelineFromFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+	state := &core.PipelineState{Results: make(map[string]map[string]*core.Data)}
+	pl.SetState(state)
+	err = pl.Run(context.Background(), slog.Default())
+	return state.Results, err
+}
+
+func TestPipelineStepCallsModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "module.yaml"), `
+params:
+  min: 10
+outputs:
+  kept: keep
+  count: count:total
+steps:
+  - name: keep
+    type: filter
+    config:
+      list: ctx.params.orders
+      condition: record.amount >= ctx.params.min
+  - name: count
+    type: script
+    inputs: [keep]
+    config:
+      script: emit("total", ctx.keep.length)
+`)
+	main := filepath.Join(dir, "main.yaml")
+	writeFile(t, main, `
+steps:
+  - name: orders
+    type: script
+    config:
+      script: "[{amount: 5}, {amount: 15}, {amount: 25}]"
+  - name: call
+    type: pipeline
+    inputs: [orders]
+    config:
+      file: `+filepath.Join(dir, "module.yaml")+`
+      params:
+        orders: ctx.orders
+  - name: call_min
+    type: pipeline
+    inputs: [orders]
+    config:
+      file: `+filepath.Join(dir, "module.yaml")+`
+      params:
+        orders: ctx.orders
+        min: "20"
+`)
+
+	results, err := runPipelineFile(t, main)
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if got := fieldValues(results["call"]["kept"].Value, "amount"); !slices.Equal(got, []any{int64(15), int64(25)}) {
+		t.Errorf("Expected the default min to keep 15 and 25, got %v", got)
+	}
+	if results["call"]["count"].Value != int64(2) {
+		t.Errorf("Expected count 2, got %v", results["call"]["count"].Value)
+	}
+	if results["call_min"]["count"].Value != int64(1) {
+		t.Errorf("Expected the min param to keep one record, got %v", results["call_min"]["count"].Value)
+	}
+	if _, ok := results["keep"]; ok {
+		t.Errorf("Steps of the called pipeline must not leak into the caller state")
+	}
+}
+
+func TestPipelineStepRelativeToFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "module.yaml"), `
+outputs:
+  default: answer
+steps:
+  - name: answer
+    type: script
+    config:
+      script: "42"
+`)
+	main := filepath.Join(dir, "main.yaml")
+	writeFile(t, main, `
+steps:
+  - name: call
+    type: pipeline
+    config:
+      file: module.yaml
+`)
+
+	// The called file is found next to the top-level pipeline, not in the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	results, err := runPipelineFile(t, main)
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if results["call"]["default"].Value != int64(42) {
+		t.Errorf("Expected 42, got %v", results["call"]["default"].Value)
+	}
+}
+
+func TestPipelineStepDetectsRecursion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), `
+steps:
+  - name: call_b
+    type: pipeline
+    config:
+      file: b.yaml
+`)
+	writeFile(t, filepath.Join(dir, "b.yaml"), `
+steps:
+  - name: call_a
+    type: pipeline
+    config:
+      file: a.yaml
+`)
+	main := filepath.Join(dir, "main.yaml")
+	writeFile(t, main, `
+steps:
+  - name: call_a
+    type: pipeline
+    config:
+      file: `+filepath.Join(dir, "a.yaml")+`
+`)
+
+	_, err := runPipelineFile(t, main)
+	if err == nil || !strings.Contains(err.Error(), "recursive pipeline call") {
+		t.Errorf("Expected a recursive call error, got %v", err)
+	}
+}
+
+func TestPipelineStepSharesConnections(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "module.yaml")
+	// An in-memory database lives as long as its pool
+	writeFile(t, module, `
+connections:
+  - name: memory
+    driver: sqlite3
+    dsn: ":memory:"
+    max_open: 1
+outputs:
+  default: count
+steps:
+  - name: create
+    type: sqlite
+    config:
+      connection: memory
+      query: CREATE TABLE IF NOT EXISTS calls (id INTEGER)
+  - name: insert
+    type: sqlite
+    inputs: [create]
+    config:
+      connection: memory
+      query: INSERT INTO calls VALUES (1)
+  - name: count
+    type: sqlite
+    inputs: [insert]
+    config:
+      connection: memory
+      query: SELECT count(*) AS n FROM calls
+`)
+	main := filepath.Join(dir, "main.yaml")
+	writeFile(t, main, `
+steps:
+  - name: items
+    type: foreach
+    config:
+      list: "[1, 2, 3, 4, 5, 6, 7, 8]"
+      parallelism: 4
+      collect: call
+      steps:
+        - name: call
+          type: pipeline
+          config:
+            file: `+module+`
+`)
+
+	// Every call of a run, concurrent or not, uses the same pool, which is
+	// closed when the run ends
+	for run := 0; run < 2; run++ {
+		results, err := runPipelineFile(t, main)
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+		var counts []any
+		for _, rows := range results["items"]["default"].Value.([]any) {
+			counts = append(counts, rows.([]map[string]any)[0]["n"])
+		}
+		if !slices.Contains(counts, any(int64(8))) || slices.Contains(counts, any(int64(9))) {
+			t.Errorf("Run %d: expected the calls to share one database for the run, got counts %v", run, counts)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"go-etl/steps"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildEchoPlugin builds the persistent test plugin in dir, named like dir,
+// and returns its command.
+func buildEchoPlugin(t *testing.T, dir string) string {
+	t.Helper()
+	return buildPlugin(t, dir, "echo_plugin")
+}
+
+// buildPlugin builds the test plugin testdata/name in dir, named like dir,
+// and returns its command.
+func buildPlugin(t *testing.T, dir, name string) string {
+	t.Helper()
+	command := filepath.Join(dir, filepath.Base(dir))
+	build := exec.Command("go", "build", "-o", command, "./testdata/"+name)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build plugin: %v\n%s", err, out)
+	}
+	manifest, err := os.ReadFile(filepath.Join("testdata", name, "plugin.json"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "plugin.json"), string(manifest))
+	return command
+}
+
+// lockedBuffer collects the logs of concurrent calls.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func runPlugin(t *testing.T, ctx context.Context, command string, logs *lockedBuffer, config map[string]any) (map[string]any, error) {
+	t.Helper()
+	stepFactory, _ := pipeline.GetStepFactory("plugin")
+	config["command"] = command
+	stepInstance, err := stepFactory("echo", config)
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+	state := &core.PipelineState{
+		Logger:  slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		Results: map[string]map[string]*core.Data{"input1": core.CreateDefaultResultData("hello")},
+	}
+	result, err := stepInstance.Run(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	return result["default"].Value.(map[string]any), nil
+}
+
+func TestPersistentPlugin(t *testing.T) {
+	command := buildEchoPlugin(t, t.TempDir())
+	logs := &lockedBuffer{}
+
+	// Concurrent calls are served by one process
+	pids := make(chan any, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1"})
+			if err != nil {
+				t.Errorf("Plugin call failed: %v", err)
+				return
+			}
+			if out["value"] != "HELLO" {
+				t.Errorf("Expected HELLO, got %v", out["value"])
+			}
+			pids <- out["pid"]
+		}()
+	}
+	wg.Wait()
+	close(pids)
+
+	var pid any
+	for p := range pids {
+		if pid != nil && p != pid {
+			t.Errorf("Expected a single plugin process, got pids %v and %v", pid, p)
+		}
+		pid = p
+	}
+	if !strings.Contains(logs.String(), "msg=echo step=echo value=hello") {
+		t.Errorf("Expected the plugin logs with the step name, got %s", logs.String())
+	}
+
+	// A cancelled call does not stop the process
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := runPlugin(t, ctx, command, logs, map[string]any{"value": "ctx.input1", "action": "wait"}); err == nil {
+		t.Error("Expected the call to be cancelled")
+	}
+	_, err := runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1", "action": "fail"})
+	if err == nil || !strings.Contains(err.Error(), "cannot echo hello") {
+		t.Errorf("Expected the plugin error, got %v", err)
+	}
+	out, err := runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1"})
+	if err != nil || out["pid"] != pid {
+		t.Errorf("Expected the same process after a cancellation, got %v %v", out, err)
+	}
+
+	// A crashed process is restarted on the next call
+	if _, err := runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1", "action": "crash"}); err == nil {
+		t.Error("Expected an error when the plugin crashes")
+	}
+	out, err = runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1"})
+	if err != nil || out["pid"] == pid {
+		t.Errorf("Expected a new process after the crash, got %v %v", out, err)
+	}
+}
+
+func TestPersistentPluginDuplicateResponse(t *testing.T) {
+	dir := t.TempDir()
+	command := buildPlugin(t, dir, "raw_plugin")
+	logs := &lockedBuffer{}
+
+	// The duplicate responses of a call must not hold the next calls
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := runPlugin(t, ctx, command, logs, map[string]any{})
+		cancel()
+		if err != nil {
+			t.Fatalf("Call %d failed: %v", i, err)
+		}
+	}
+
+	// The plugin receives a shutdown request when the engine stops
+	steps.StopPlugins()
+	if _, err := os.Stat(filepath.Join(dir, "shutdown")); err != nil {
+		t.Errorf("Expected the plugin to receive the shutdown request: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const manifestInputs = `
+    "inputs": {
+        "value": {"type": "text", "label": "Value", "required": true, "interpolation": true, "max": 10},
+        "mode": {"type": "text", "label": "Mode", "enum": ["fast", "safe"], "default": "safe"},
+        "limit": {"type": "integer", "label": "Limit", "min": 1, "max": 100},
+        "tags": {"type": "list", "label": "Tags", "max": 2},
+        "password": {"type": "text", "label": "Password", "required": true, "secret": true}
+    }`
+
+// newManifestPlugin writes a plugin directory with the manifest and returns
+// its command; the command is a built echo plugin when build is set.
+func newManifestPlugin(t *testing.T, manifest string, build bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	command := filepath.Join(dir, filepath.Base(dir))
+	if build {
+		command = buildEchoPlugin(t, dir)
+	} else {
+		writeFile(t, command, "")
+	}
+	writeFile(t, filepath.Join(dir, "plugin.json"), manifest)
+	return command
+}
+
+func TestPluginManifestValidation(t *testing.T) {
+	command := newManifestPlugin(t, `{"name": "Checked", "version": "1.0.0", `+manifestInputs+`}`, false)
+	stepFactory, _ := pipeline.GetStepFactory("plugin")
+
+	valid := map[string]any{"command": command, "value": "ctx.input1", "password_secret": "env:ETL_TEST_PASSWORD"}
+	if _, err := stepFactory("checked", valid); err != nil {
+		t.Errorf("Expected a valid configuration, got %v", err)
+	}
+
+	cases := []struct {
+		config   map[string]any
+		without  string
+		expected string
+	}{
+		{map[string]any{"limit": 0}, "", "input 'limit' of " + filepath.Join(filepath.Dir(command), "plugin.json") + " must be at least 1, got 0"},
+		{map[string]any{"limit": 1.5}, "", "must be an integer"},
+		{map[string]any{"limit": "ten"}, "", "must be a number, got string"},
+		{map[string]any{"mode": "slow"}, "", "must be one of fast, safe, got slow"},
+		{map[string]any{"tags": []any{"a", "b", "c"}}, "", "must have a length of at most 2"},
+		{map[string]any{"password": 1234}, "", "must be text, got int"},
+		{map[string]any{"other": true}, "", "unknown input 'other'"},
+		{nil, "password", "missing required input 'password'"},
+	}
+	for _, c := range cases {
+		config := map[string]any{"command": command, "value": "ctx.input1", "password": "pa55"}
+		for key, value := range c.config {
+			config[key] = value
+		}
+		delete(config, c.without)
+		_, err := stepFactory("checked", config)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("Config %v: expected error %q, got %v", c.config, c.expected, err)
+		}
+	}
+
+	broken := newManifestPlugin(t, `{"name": "Broken", "inputs": {"value": {"type": "date"}}}`, false)
+	if _, err := stepFactory("broken", map[string]any{"command": broken}); err == nil || !strings.Contains(err.Error(), "input 'value' has unknown type 'date'") {
+		t.Errorf("Expected an invalid manifest error, got %v", err)
+	}
+}
+
+func TestPluginManifestOutputs(t *testing.T) {
+	t.Setenv("ETL_TEST_PASSWORD", "s3cret")
+	command := newManifestPlugin(t, `{"name": "Echo", "version": "1.0.0", "protocol": "jsonrpc", `+manifestInputs+`,
+    "outputs": {
+        "value": {"type": "text", "label": "Uppercase value"},
+        "pid": {"type": "integer", "label": "Process"},
+        "inputs": {"type": "object", "label": "Plugin inputs"}
+    }}`, true)
+
+	stepFactory, _ := pipeline.GetStepFactory("plugin")
+	stepInstance, err := stepFactory("echo", map[string]any{"command": command, "value": "ctx.input1", "password_secret": "env:ETL_TEST_PASSWORD"})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	state := &core.PipelineState{Logger: slog.Default(), Results: map[string]map[string]*core.Data{"input1": core.CreateDefaultResultData("hello")}}
+	result, err := stepInstance.Run(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	if _, ok := result["default"]; ok || result["value"].Value != "HELLO" {
+		t.Errorf("Expected the declared outputs only, got %v", result)
+	}
+	inputs := result["inputs"].Value.(map[string]any)
+	if inputs["mode"] != "safe" || inputs["password"] != "s3cret" || inputs["limit"] != nil {
+		t.Errorf("Expected the default mode and the resolved secret, got %v", inputs)
+	}
+
+	// Interpolated values are checked once resolved
+	state.Results["input1"] = core.CreateDefaultResultData("a long value")
+	if _, err := stepInstance.Run(context.Background(), state); err == nil || !strings.Contains(err.Error(), "must have a length of at most 10") {
+		t.Errorf("Expected a constraint error, got %v", err)
+	}
+}
+
+func TestPluginSecretInputNotInterpolated(t *testing.T) {
+	t.Setenv("ETL_TEST_PLUGIN_TOKEN", "s3cr3t-pass")
+	command := newManifestPlugin(t, `{"name": "Echo", "version": "1.0.0", "protocol": "jsonrpc", "inputs": {
+        "value": {"type": "text", "label": "Value", "interpolation": true},
+        "token": {"type": "text", "label": "Token", "required": true, "secret": true, "interpolation": true}
+    }}`, true)
+
+	stepFactory, _ := pipeline.GetStepFactory("plugin")
+	run := func(config map[string]any) (map[string]*core.Data, error) {
+		config["command"] = command
+		config["value"] = "ctx.input1"
+		stepInstance, err := stepFactory("echo", config)
+		if err != nil {
+			t.Fatalf("Failed to create step instance: %v", err)
+		}
+		state := &core.PipelineState{Logger: slog.Default(), Results: map[string]map[string]*core.Data{"input1": core.CreateDefaultResultData("hello")}}
+		return stepInstance.Run(context.Background(), state)
+	}
+
+	// A referenced secret is passed as is, even though it is not a valid expression
+	result, err := run(map[string]any{"token_secret": "env:ETL_TEST_PLUGIN_TOKEN"})
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	inputs := result["default"].Value.(map[string]any)["inputs"].(map[string]any)
+	if inputs["token"] != "s3cr3t-pass" {
+		t.Errorf("Expected the secret as is, got %v", inputs["token"])
+	}
+
+	// A plain secret value failing to interpolate is not shown in the error
+	_, err = run(map[string]any{"token": "s3cr3t-pass"})
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("Expected an interpolation error without the secret, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"encoding/json"
+	"go-etl/pipeline"
+	"go-etl/steps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	// Step types are registered for the whole process, the directory name makes this one unique
+	pluginDir, err := os.MkdirTemp(dir, "echo*_plugin")
+	if err != nil {
+		t.Fatalf("Failed to create plugin directory: %v", err)
+	}
+	buildEchoPlugin(t, pluginDir)
+	stepType := strings.TrimSuffix(filepath.Base(pluginDir), "_plugin")
+
+	broken := filepath.Join(dir, "broken_plugin")
+	os.Mkdir(broken, 0o755)
+	writeFile(t, filepath.Join(broken, "plugin.json"), `{"name": "Broken"}`)
+	os.Mkdir(filepath.Join(dir, "not_a_plugin"), 0o755)
+
+	err = steps.LoadPlugins([]string{dir})
+	if err == nil || !strings.Contains(err.Error(), "broken_plugin") || strings.Contains(err.Error(), stepType) {
+		t.Errorf("Expected only the broken plugin to be reported, got %v", err)
+	}
+
+	var found bool
+	for _, plugin := range steps.InstalledPlugins() {
+		if plugin.Type == stepType {
+			found = plugin.Configuration.Name == "Echo Plugin"
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s in the installed plugins, got %v", stepType, steps.InstalledPlugins())
+	}
+
+	if err := steps.LoadPlugins([]string{dir}); err == nil || !strings.Contains(err.Error(), "already provided") {
+		t.Errorf("Expected an error for a plugin loaded twice, got %v", err)
+	}
+
+	// The plugin is a step type of its own
+	events, err := runPipeline(t, []pipeline.StepConfig{
+		{Name: "input1", Type: "script", Config: map[string]any{"script": "'hello'"}},
+		{Name: "echo", Type: stepType, Inputs: []string{"input1"}, Config: map[string]any{"value": "ctx.input1"}},
+	})
+	if err != nil || events["echo"] != "end" {
+		t.Errorf("Expected the plugin step to run, got %v %v", events, err)
+	}
+}
+
+func TestInstalledPluginsHideSecrets(t *testing.T) {
+	dir := t.TempDir()
+	pluginDir, err := os.MkdirTemp(dir, "secret*_plugin")
+	if err != nil {
+		t.Fatalf("Failed to create plugin directory: %v", err)
+	}
+	writeFile(t, filepath.Join(pluginDir, filepath.Base(pluginDir)), "#!/bin/sh\n")
+	writeFile(t, filepath.Join(pluginDir, "plugin.json"), `{
+    "name": "Secret Plugin",
+    "version": "1.0.0",
+    "inputs": {
+        "user": {"type": "text", "label": "User", "default": "etl"},
+        "password": {"type": "text", "label": "Password", "secret": true, "default": "hunter2"}
+    }
+}`)
+	if err := steps.LoadPlugins([]string{dir}); err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+
+	stepType := strings.TrimSuffix(filepath.Base(pluginDir), "_plugin")
+	for _, plugin := range steps.InstalledPlugins() {
+		if plugin.Type != stepType {
+			continue
+		}
+		listing, _ := json.Marshal(plugin)
+		if strings.Contains(string(listing), "hunter2") {
+			t.Errorf("Expected the secret default to be hidden, got %s", listing)
+		}
+		if plugin.Configuration.Inputs["user"].Default != "etl" {
+			t.Errorf("Expected the other defaults to be listed, got %s", listing)
+		}
+		return
+	}
+	t.Errorf("Expected %s in the installed plugins", stepType)
+}
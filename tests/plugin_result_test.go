@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-etl/core"
+	"strings"
+	"testing"
+)
+
+func TestPluginResult(t *testing.T) {
+	command := buildPlugin(t, t.TempDir(), "exec_plugin")
+	logs := &lockedBuffer{}
+
+	out, err := runPlugin(t, context.Background(), command, logs, map[string]any{"action": "ok"})
+	if err != nil {
+		t.Fatalf("Plugin call failed: %v", err)
+	}
+	if out["ok"] != true {
+		t.Errorf("Expected the outputs of the envelope, got %v", out)
+	}
+	if !strings.Contains(logs.String(), "level=WARN msg=\"almost full\" step=echo usage=0.9") {
+		t.Errorf("Expected the plugin log with the step name, got %s", logs.String())
+	}
+
+	_, err = runPlugin(t, context.Background(), command, logs, map[string]any{"action": "fail"})
+	var pluginErr *core.PluginError
+	if !errors.As(err, &pluginErr) {
+		t.Fatalf("Expected a plugin error, got %v", err)
+	}
+	if pluginErr.Step != "echo" || pluginErr.Code != "quota_exceeded" || pluginErr.Message != "daily quota reached" || !pluginErr.Retryable {
+		t.Errorf("Unexpected plugin error %+v", pluginErr)
+	}
+
+	// Plain output is not taken for an envelope because of its keys
+	out, err = runPlugin(t, context.Background(), command, logs, map[string]any{"action": "plain"})
+	if err != nil || out["ok"] != false || out["error"] != "not an envelope" {
+		t.Errorf("Expected the plain output, got %v %v", out, err)
+	}
+
+	_, err = runPlugin(t, context.Background(), command, logs, map[string]any{"action": "silent"})
+	if !errors.As(err, &pluginErr) || pluginErr.Code != "no_output" {
+		t.Errorf("Expected a no_output error, got %v", err)
+	}
+
+	_, err = runPlugin(t, context.Background(), command, logs, map[string]any{"action": "crash"})
+	if err == nil || errors.As(err, &pluginErr) {
+		t.Errorf("Expected the exit error of the plugin, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN msg=\"panic: out of memory\" step=echo stream=stderr") {
+		t.Errorf("Expected the stderr line in the logs, got %s", logs.String())
+	}
+}
+
+func TestPersistentPluginError(t *testing.T) {
+	command := buildEchoPlugin(t, t.TempDir())
+	logs := &lockedBuffer{}
+
+	var pluginErr *core.PluginError
+	_, err := runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1", "action": "busy"})
+	if !errors.As(err, &pluginErr) || pluginErr.Code != "busy" || !pluginErr.Retryable {
+		t.Errorf("Expected a retryable busy error, got %v", err)
+	}
+
+	_, err = runPlugin(t, context.Background(), command, logs, map[string]any{"value": "ctx.input1", "action": "fail"})
+	if !errors.As(err, &pluginErr) || pluginErr.Code != "plugin_error" || pluginErr.Retryable {
+		t.Errorf("Expected a plugin_error error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := core.RegisterRateLimiter("test_limiter", 50, 2)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Errorf("Wait failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The burst lets 2 requests through, the 4 others wait 20ms each
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected the requests to be spread over 80ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Pause(time.Second)
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Expected a paused limiter to stop waiting on cancellation")
+	}
+
+	if err := core.WaitRateLimiter(context.Background(), "test_unknown"); err == nil {
+		t.Error("Expected an error for an unknown rate limiter")
+	}
+}
+
+// newTooManyServer answers 429 to the first failures requests.
+func newTooManyServer(t *testing.T, failures int32, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestHTTPClientRetryTooManyRequests(t *testing.T) {
+	server, calls := newTooManyServer(t, 2, "")
+	res, errRes := runResponse(t, map[string]any{"url": server.URL, "method": "GET", "retry_delay_ms": 10})
+	if res == nil || errRes != nil || calls.Load() != 3 {
+		t.Errorf("Expected a success after 2 retries, got %v %v after %d calls", res, errRes, calls.Load())
+	}
+
+	server, calls = newTooManyServer(t, 1, "1")
+	start := time.Now()
+	res, _ = runResponse(t, map[string]any{"url": server.URL, "method": "GET", "retry_delay_ms": 10})
+	if res == nil || time.Since(start) < time.Second {
+		t.Errorf("Expected a retry after the Retry-After delay, got %v after %v", res, time.Since(start))
+	}
+
+	server, calls = newTooManyServer(t, 5, "")
+	_, errRes = runResponse(t, map[string]any{"url": server.URL, "method": "GET", "max_retries": 1, "retry_delay_ms": 10})
+	if errRes == nil || errRes.StatusCode != http.StatusTooManyRequests || calls.Load() != 2 {
+		t.Errorf("Expected the 429 as error output after 1 retry, got %v after %d calls", errRes, calls.Load())
+	}
+}
+
+func TestRateLimiterSharedByForeach(t *testing.T) {
+	server, calls := newTooManyServer(t, 0, "")
+
+	pl, err := pipeline.LoadPipeline(pipeline.PipelineConfig{
+		RateLimiters: []pipeline.RateLimiterConfig{{Name: "test_api", Rate: 20, Burst: 1}},
+		Steps: []pipeline.StepConfig{{
+			Name: "calls",
+			Type: "foreach",
+			Config: map[string]any{
+				"list":        "[1, 2, 3, 4, 5]",
+				"parallelism": 5,
+				"steps": []any{
+					map[string]any{"name": "call", "type": "http client", "config": map[string]any{
+						"url":          server.URL + "/items/${ctx.foreach.item}",
+						"method":       "GET",
+						"rate_limiter": "test_api",
+					}},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	start := time.Now()
+	if err := pl.Run(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	// One request every 50ms
+	if elapsed := time.Since(start); calls.Load() != 5 || elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 5 rate limited calls over 200ms, got %d in %v", calls.Load(), elapsed)
+	}
+}
@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// runStep creates a step of stepType and runs it with input1 holding input.
+func runStep(t *testing.T, stepType string, config map[string]any, input any) map[string]*core.Data {
+	t.Helper()
+	return runStepWith(t, stepType, config, map[string]any{"input1": input})
+}
+
+// newStep creates a step of stepType.
+func newStep(t *testing.T, stepType string, config map[string]any) core.Step {
+	t.Helper()
+	stepFactory, ok := pipeline.GetStepFactory(stepType)
+	if !ok {
+		t.Fatalf("Step type '%s' not registered", stepType)
+	}
+
+	stepInstance, err := stepFactory("test_"+stepType, config)
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+	return stepInstance
+}
+
+// runStepWith creates a step of stepType and runs it with the given default outputs of previous steps.
+func runStepWith(t *testing.T, stepType string, config map[string]any, inputs map[string]any) map[string]*core.Data {
+	t.Helper()
+	stepInstance := newStep(t, stepType, config)
+
+	state := &core.PipelineState{
+		Logger:  slog.Default(),
+		Results: make(map[string]map[string]*core.Data),
+	}
+	for name, input := range inputs {
+		state.Set(name, core.CreateDefaultResultData(input))
+	}
+	result, err := stepInstance.Run(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	return result
+}
+
+func fieldValues(records any, field string) []any {
+	var values []any
+	for _, record := range records.([]any) {
+		values = append(values, record.(map[string]any)[field])
+	}
+	return values
+}
+
+var people = []any{
+	map[string]any{"name": "Carol", "age": 35, "city": "Rome"},
+	map[string]any{"name": "Alice", "age": 30, "city": "Milan"},
+	map[string]any{"name": "Bob", "age": nil, "city": "Rome"},
+	map[string]any{"name": "Dave", "age": 30, "city": "Turin"},
+}
+
+func TestFilter(t *testing.T) {
+	result := runStep(t, "filter", map[string]any{
+		"list":      "ctx.input1",
+		"condition": "record.city === 'Rome'",
+	}, people)
+
+	names := fieldValues(result["default"].Value, "name")
+	if !reflect.DeepEqual(names, []any{"Carol", "Bob"}) {
+		t.Errorf("Expected [Carol Bob], got %v", names)
+	}
+}
+
+func TestSortMultiKey(t *testing.T) {
+	result := runStep(t, "sort", map[string]any{
+		"list": "ctx.input1",
+		"by": []any{
+			map[string]any{"field": "age", "order": "desc", "nulls": "first"},
+			"name",
+		},
+	}, people)
+
+	names := fieldValues(result["default"].Value, "name")
+	if !reflect.DeepEqual(names, []any{"Bob", "Carol", "Alice", "Dave"}) {
+		t.Errorf("Expected [Bob Carol Alice Dave], got %v", names)
+	}
+}
+
+func TestSortStableByExpression(t *testing.T) {
+	result := runStep(t, "sort", map[string]any{
+		"list": "ctx.input1",
+		"by":   []any{map[string]any{"key": "record.city.length"}},
+	}, people)
+
+	names := fieldValues(result["default"].Value, "name")
+	if !reflect.DeepEqual(names, []any{"Carol", "Bob", "Alice", "Dave"}) {
+		t.Errorf("Expected [Carol Bob Alice Dave], got %v", names)
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	result := runStep(t, "limit", map[string]any{
+		"list":   "ctx.input1",
+		"limit":  2,
+		"offset": 3,
+	}, people)
+
+	names := fieldValues(result["default"].Value, "name")
+	if !reflect.DeepEqual(names, []any{"Dave"}) {
+		t.Errorf("Expected [Dave], got %v", names)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	orders := []any{
+		map[string]any{"customer": "a", "amount": 10, "item": "x"},
+		map[string]any{"customer": "b", "amount": 5.5, "item": "y"},
+		map[string]any{"customer": "a", "amount": 20, "item": "x"},
+		map[string]any{"customer": "a", "amount": nil, "item": "z"},
+	}
+	result := runStep(t, "aggregate", map[string]any{
+		"list":     "ctx.input1",
+		"group_by": []any{"customer"},
+		"aggregates": []any{
+			map[string]any{"name": "orders", "op": "count"},
+			map[string]any{"name": "total", "op": "sum", "field": "amount"},
+			map[string]any{"name": "average", "op": "avg", "field": "amount"},
+			map[string]any{"name": "largest", "op": "max", "field": "amount"},
+			map[string]any{"name": "items", "op": "count_distinct", "field": "item"},
+			map[string]any{"name": "doubled", "op": "collect", "value": "record.amount * 2"},
+		},
+	}, orders)
+
+	groups := result["default"].Value.([]any)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %v", groups)
+	}
+	a := groups[0].(map[string]any)
+	if a["customer"] != "a" || a["orders"] != int64(3) || a["total"] != int64(30) ||
+		a["average"] != 15.0 || a["largest"] != 20 || a["items"] != int64(2) {
+		t.Errorf("Unexpected aggregates for group a: %v", a)
+	}
+	if len(a["doubled"].([]any)) != 3 {
+		t.Errorf("Expected 3 collected values, got %v", a["doubled"])
+	}
+	b := groups[1].(map[string]any)
+	if b["customer"] != "b" || b["total"] != 5.5 {
+		t.Errorf("Unexpected aggregates for group b: %v", b)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	orders := []any{
+		map[string]any{"id": 1, "customer_id": 10, "total": 5},
+		map[string]any{"id": 2, "customer_id": 20, "total": 7},
+		map[string]any{"id": 3, "customer_id": 99, "total": 9},
+	}
+	customers := []any{
+		map[string]any{"id": int64(10), "name": "Alice"},
+		map[string]any{"id": int64(20), "name": "Bob"},
+		map[string]any{"id": int64(30), "name": "Carol"},
+	}
+	inputs := map[string]any{"orders": orders, "customers": customers}
+
+	tests := []struct {
+		joinType string
+		expected []any
+	}{
+		{"inner", []any{1, 2}},
+		{"left", []any{1, 2, 3}},
+		{"right", []any{1, 2, int64(30)}},
+		{"full", []any{1, 2, 3, int64(30)}},
+		{"semi", []any{1, 2}},
+		{"anti", []any{3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.joinType, func(t *testing.T) {
+			result := runStepWith(t, "join", map[string]any{
+				"left":      "ctx.orders",
+				"right":     "ctx.customers",
+				"left_key":  "record.customer_id",
+				"right_key": "record.id",
+				"type":      test.joinType,
+			}, inputs)
+
+			ids := fieldValues(result["default"].Value, "id")
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("Expected ids %v, got %v", test.expected, ids)
+			}
+		})
+	}
+
+	result := runStepWith(t, "join", map[string]any{
+		"left":         "ctx.orders",
+		"right":        "ctx.customers",
+		"left_key":     "record.customer_id",
+		"right_key":    "record.id",
+		"right_prefix": "c_",
+	}, inputs)
+	first := result["default"].Value.([]any)[0].(map[string]any)
+	if first["id"] != 1 || first["c_id"] != int64(10) || first["name"] != "Alice" {
+		t.Errorf("Unexpected joined record %v", first)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	versions := []any{
+		map[string]any{"id": 1, "version": 1, "name": "a"},
+		map[string]any{"id": 2, "version": 1, "name": "b"},
+		map[string]any{"id": 1, "version": 3, "name": "c"},
+		map[string]any{"id": 1, "version": 2, "name": "d"},
+	}
+
+	tests := map[string][]any{
+		"first": {"a", "b"},
+		"last":  {"d", "b"},
+		"max":   {"c", "b"},
+	}
+	for keep, expected := range tests {
+		result := runStep(t, "dedupe", map[string]any{
+			"list":  "ctx.input1",
+			"key":   "record.id",
+			"keep":  keep,
+			"field": "version",
+		}, versions)
+
+		names := fieldValues(result["default"].Value, "name")
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("keep %s: expected %v, got %v", keep, expected, names)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	previous := []any{
+		map[string]any{"id": int64(1), "name": "a"},
+		map[string]any{"id": int64(2), "name": "b"},
+		map[string]any{"id": int64(3), "name": "c"},
+	}
+	current := []any{
+		map[string]any{"id": 1, "name": "a"},
+		map[string]any{"id": 2, "name": "B"},
+		map[string]any{"id": 4, "name": "d"},
+	}
+
+	result := runStepWith(t, "diff", map[string]any{
+		"previous": "ctx.previous",
+		"current":  "ctx.current",
+		"key":      "record.id",
+	}, map[string]any{"previous": previous, "current": current})
+
+	expected := map[string][]any{
+		"added":     {"d"},
+		"removed":   {"c"},
+		"changed":   {"B"},
+		"unchanged": {"a"},
+	}
+	for output, names := range expected {
+		data, ok := result[output]
+		if !ok {
+			t.Errorf("Missing output %s", output)
+			continue
+		}
+		if got := fieldValues(data.Value, "name"); !reflect.DeepEqual(got, names) {
+			t.Errorf("Output %s: expected %v, got %v", output, names, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScriptProgram(t *testing.T) {
+	result := runStep(t, "script", map[string]any{
+		"inputs": map[string]any{"factor": "2"},
+		"script": `
+			let total = 0;
+			for (const r of ctx.input1) {
+				total += r.amount;
+			}
+			emit("count", ctx.input1.length);
+			log.info("computed total", {total: total});
+			total * inputs.factor;
+		`,
+	}, []any{map[string]any{"amount": 3}, map[string]any{"amount": 4}})
+
+	if result["default"].Value != int64(14) {
+		t.Errorf("Expected 14, got %v (%T)", result["default"].Value, result["default"].Value)
+	}
+	if result["count"].Value != int64(2) {
+		t.Errorf("Expected count output 2, got %v", result["count"].Value)
+	}
+}
+
+func TestScriptNamedOutputsInContext(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("script")
+	stepInstance, err := stepFactory("testScriptContext", map[string]any{
+		"script": "[ctx.counted.default, ctx.counted.count, ctx.merged.total, ctx.merged.count]",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	// A step emitting named outputs besides a scalar or an object default output
+	state := &core.PipelineState{Results: map[string]map[string]*core.Data{
+		"counted": {"default": &core.Data{Value: 14}, "count": &core.Data{Value: 2}},
+		"merged":  {"default": &core.Data{Value: map[string]any{"total": 14}}, "count": &core.Data{Value: 2}},
+	}}
+	result, err := stepInstance.Run(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	expected := []any{int64(14), int64(2), int64(14), int64(2)}
+	if got, _ := result["default"].Value.([]any); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, result["default"].Value)
+	}
+}
+
+func TestScriptTransform(t *testing.T) {
+	input := []any{
+		map[string]any{"id": 1, "tags": "a,b"},
+		map[string]any{"id": 2, "tags": ""},
+		map[string]any{"id": 3, "tags": "c"},
+	}
+	result := runStep(t, "script", map[string]any{
+		"list": "ctx.input1",
+		"script": `
+			function transform(record, index) {
+				if (!record.tags) {
+					return null;
+				}
+				return record.tags.split(",").map(tag => ({id: record.id, tag: tag, index: index}));
+			}
+		`,
+	}, input)
+
+	records := result["default"].Value.([]any)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %v", records)
+	}
+	last := records[2].(map[string]any)
+	if last["tag"] != "c" || last["index"] != int64(2) {
+		t.Errorf("Unexpected last record %v", last)
+	}
+	if _, ok := input[0].(map[string]any)["index"]; ok {
+		t.Errorf("Input records must not be modified")
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("script")
+	stepInstance, err := stepFactory("testScriptTimeout", map[string]any{
+		"script":     "while (true) {}",
+		"timeout_ms": 50,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	_, err = stepInstance.Run(context.Background(), &core.PipelineState{})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+func TestScriptMissingTransform(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("script")
+	stepInstance, err := stepFactory("testScriptMissing", map[string]any{
+		"list":   "ctx.input1",
+		"script": "let x = 1;",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	state := &core.PipelineState{Results: map[string]map[string]*core.Data{
+		"input1": core.CreateDefaultResultData([]any{}),
+	}}
+	if _, err := stepInstance.Run(context.Background(), state); err == nil {
+		t.Errorf("Expected an error when transform is not defined")
+	}
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"path/filepath"
+	"testing"
+)
+
+func runSqlLoad(t *testing.T, config map[string]any, records []any) map[string]any {
+	stepFactory, ok := pipeline.GetStepFactory("sql_load")
+	if !ok {
+		t.Fatalf("Step type 'sql_load' not registered")
+	}
+
+	stepInstance, err := stepFactory("testSqlLoad", config)
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	state := &core.PipelineState{
+		Results: map[string]map[string]*core.Data{
+			"input1": core.CreateDefaultResultData(records),
+		},
+	}
+	result, err := stepInstance.Run(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	return result["default"].Value.(map[string]any)
+}
+
+func TestSqlLoadUpsert(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "load.db")
+
+	counts := runSqlLoad(t, map[string]any{
+		"connection":   dbPath,
+		"table":        "customers",
+		"records":      "ctx.input1",
+		"keys":         []any{"id"},
+		"mode":         "upsert",
+		"batch_size":   2,
+		"create_table": true,
+	}, []any{
+		map[string]any{"id": 1, "name": "Alice"},
+		map[string]any{"id": 2, "name": "Bob"},
+		map[string]any{"id": 3, "name": "Carol"},
+	})
+	if counts["inserted"] != int64(3) || counts["updated"] != int64(0) {
+		t.Errorf("Expected 3 inserted and 0 updated, got %v", counts)
+	}
+
+	counts = runSqlLoad(t, map[string]any{
+		"connection": dbPath,
+		"table":      "customers",
+		"records":    "ctx.input1",
+		"keys":       []any{"id"},
+		"mode":       "upsert",
+	}, []any{
+		map[string]any{"id": 2, "name": "Robert"},
+		map[string]any{"id": 4, "name": "Dave"},
+	})
+	if counts["inserted"] != int64(1) || counts["updated"] != int64(1) {
+		t.Errorf("Expected 1 inserted and 1 updated, got %v", counts)
+	}
+
+	stepFactory, _ := pipeline.GetStepFactory("sqlite")
+	selectStep, err := stepFactory("testSqlLoadSelect", map[string]any{
+		"connection": dbPath,
+		"query":      "SELECT name FROM customers WHERE id = 2",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+	result, err := selectStep.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+	rows := result["default"].Value.([]map[string]any)
+	if len(rows) != 1 || rows[0]["name"] != "Robert" {
+		t.Errorf("Expected updated name 'Robert', got %v", rows)
+	}
+}
+
+func TestSqlLoadTruncate(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "load.db")
+	config := map[string]any{
+		"connection":   dbPath,
+		"table":        "items",
+		"records":      "ctx.input1",
+		"mode":         "truncate",
+		"columns":      map[string]any{"code": "id", "label": "description"},
+		"create_table": true,
+	}
+	records := []any{
+		map[string]any{"id": "a", "description": "first"},
+		map[string]any{"id": "b", "description": "second"},
+	}
+
+	runSqlLoad(t, config, records)
+	counts := runSqlLoad(t, config, records)
+	if counts["inserted"] != int64(2) {
+		t.Errorf("Expected 2 inserted after truncate, got %v", counts)
+	}
+}
+
+func TestSqlLoadTruncateIsAtomic(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "load.db")
+	execSql(t, dbPath, "CREATE TABLE items (code TEXT PRIMARY KEY)")
+	execSql(t, dbPath, "INSERT INTO items VALUES ('old')")
+
+	stepFactory, _ := pipeline.GetStepFactory("sql_load")
+	stepInstance, err := stepFactory("testSqlLoad", map[string]any{
+		"connection": dbPath,
+		"table":      "items",
+		"records":    "ctx.input1",
+		"mode":       "truncate",
+		"batch_size": 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+	// The second batch violates the primary key
+	state := &core.PipelineState{Results: map[string]map[string]*core.Data{
+		"input1": core.CreateDefaultResultData([]any{map[string]any{"code": "a"}, map[string]any{"code": "a"}}),
+	}}
+	if _, err := stepInstance.Run(context.Background(), state); err == nil {
+		t.Fatal("Expected the second batch to fail")
+	}
+
+	rows := runStep(t, "sqlite", map[string]any{"connection": dbPath, "query": "SELECT code FROM items"}, nil)
+	if got := rows["default"].Value.([]map[string]any); len(got) != 1 || got[0]["code"] != "old" {
+		t.Errorf("Expected the previous rows to be kept, got %v", got)
+	}
+}
+
+func TestSqlLoadRequiresKeys(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("sql_load")
+	_, err := stepFactory("testSqlLoad", map[string]any{
+		"connection": "unused.db",
+		"table":      "t",
+		"records":    "ctx.input1",
+		"mode":       "upsert",
+	})
+	if err == nil {
+		t.Error("Expected error for upsert without keys")
+	}
+}
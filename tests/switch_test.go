@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"go-etl/core"
+	"go-etl/pipeline"
+	"maps"
+	"slices"
+	"testing"
+)
+
+var switchCases = []any{
+	map[string]any{"name": "eu", "condition": "value.region == 'eu'"},
+	map[string]any{"name": "us", "condition": "value.region == 'us'"},
+}
+
+func TestSwitchRoute(t *testing.T) {
+	order := map[string]any{"id": 1, "region": "us"}
+	result := runStep(t, "switch", map[string]any{
+		"value": "ctx.input1",
+		"cases": switchCases,
+	}, order)
+
+	if len(result) != 1 || result["us"] == nil {
+		t.Fatalf("Expected only the us output, got %v", result)
+	}
+	if result["us"].Value.(map[string]any)["id"] != 1 {
+		t.Errorf("Expected the input value on the us output, got %v", result["us"].Value)
+	}
+
+	result = runStep(t, "switch", map[string]any{
+		"value":   "ctx.input1",
+		"cases":   switchCases,
+		"default": "other",
+	}, map[string]any{"region": "apac"})
+	if len(result) != 1 || result["other"] == nil {
+		t.Errorf("Expected only the other output, got %v", result)
+	}
+}
+
+func TestSwitchPartition(t *testing.T) {
+	result := runStep(t, "switch", map[string]any{
+		"value": "ctx.input1",
+		"mode":  "partition",
+		"cases": []any{
+			map[string]any{"name": "eu", "condition": "record.region == 'eu'"},
+			map[string]any{"name": "us", "condition": "record.region == 'us'"},
+		},
+	}, []any{
+		map[string]any{"id": 1, "region": "eu"},
+		map[string]any{"id": 2, "region": "us"},
+		map[string]any{"id": 3, "region": "eu"},
+		map[string]any{"id": 4, "region": "apac"},
+	})
+
+	expected := map[string][]any{"eu": {1, 3}, "us": {2}, "default": {4}}
+	for output, ids := range expected {
+		if got := fieldValues(result[output].Value.([]any), "id"); !slices.Equal(got, ids) {
+			t.Errorf("Output %s: expected %v, got %v", output, ids, got)
+		}
+	}
+}
+
+func TestSwitchSkipsUntakenBranches(t *testing.T) {
+	events, err := runPipeline(t, []pipeline.StepConfig{
+		{Name: "order", Type: "script", Config: map[string]any{"script": "({region: 'eu'})"}},
+		{Name: "route", Type: "switch", Inputs: []string{"order"}, Config: map[string]any{
+			"value": "ctx.order",
+			"cases": switchCases,
+		}},
+		{Name: "eu", Type: "script", Inputs: []string{"route:eu"}, Config: map[string]any{"script": "ctx.route.eu.region"}},
+		{Name: "us", Type: "script", Inputs: []string{"route:us"}, Config: map[string]any{"script": "ctx.route.us.region"}},
+		{Name: "after_us", Type: "script", Inputs: []string{"us"}, Config: map[string]any{"script": "ctx.us"}},
+	})
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	expected := map[string]core.ChangeEventType{
+		"order":    core.ChangeEventTypeEnd,
+		"route":    core.ChangeEventTypeEnd,
+		"eu":       core.ChangeEventTypeEnd,
+		"us":       core.ChangeEventTypeSkip,
+		"after_us": core.ChangeEventTypeSkip,
+	}
+	if !maps.Equal(events, expected) {
+		t.Errorf("Expected step outcomes %v, got %v", expected, events)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	sdk "go-etl-sdk"
+)
+
+type PluginInput struct {
+	Value  string `json:"value"`
+	Action string `json:"action"`
+}
+
+func main() {
+	err := sdk.Serve("echo", "1.0.0", func(ctx context.Context, call *sdk.Call) (any, error) {
+		var input PluginInput
+		if err := call.Decode(&input); err != nil {
+			return nil, err
+		}
+
+		switch input.Action {
+		case "wait":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "crash":
+			os.Exit(2)
+		case "fail":
+			return nil, fmt.Errorf("cannot echo %s", input.Value)
+		case "busy":
+			return nil, sdk.NewError("busy", "try again later", true)
+		}
+
+		var inputs map[string]any
+		if err := call.Decode(&inputs); err != nil {
+			return nil, err
+		}
+
+		call.Log("info", "echo", map[string]any{"value": input.Value})
+		return map[string]any{"value": strings.ToUpper(input.Value), "pid": os.Getpid(), "inputs": inputs}, nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	sdk "go-etl-sdk"
+)
+
+type PluginInput struct {
+	Action string `json:"action"`
+}
+
+func main() {
+	input, err := sdk.ReadInput[PluginInput]()
+	if err != nil {
+		sdk.Fail(err)
+	}
+
+	switch input.Action {
+	case "fail":
+		sdk.Fail(sdk.NewError("quota_exceeded", "daily quota reached", true))
+	case "silent":
+		return
+	case "plain":
+		// Output of a plugin written without the SDK
+		fmt.Print(`{"ok": false, "error": "not an envelope"}`)
+		return
+	case "crash":
+		fmt.Fprintln(os.Stderr, "panic: out of memory")
+		os.Exit(2)
+	}
+
+	sdk.Log("warn", "almost full", map[string]any{"usage": 0.9})
+	sdk.WriteOutput(map[string]any{"ok": true, "rows": []int{1, 2}})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	sdk "go-etl-sdk"
+)
+
+// The plugin speaks the protocol without the SDK: it answers every run three times
+// and records the shutdown request in a file next to its command.
+func main() {
+	enc := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg sdk.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		switch msg.Method {
+		case sdk.MethodInitialize:
+			result, _ := json.Marshal(sdk.InitializeResult{ProtocolVersion: sdk.ProtocolVersion, Name: "raw", Version: "1.0.0"})
+			enc.Encode(sdk.Message{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		case sdk.MethodRun:
+			result, _ := json.Marshal(map[string]any{"pid": os.Getpid()})
+			line, _ := json.Marshal(sdk.Message{JSONRPC: "2.0", ID: msg.ID, Result: result})
+			line = append(line, '\n')
+			os.Stdout.Write(bytes.Repeat(line, 3))
+		case sdk.MethodShutdown:
+			command, _ := os.Executable()
+			os.WriteFile(filepath.Join(filepath.Dir(command), "shutdown"), nil, 0o644)
+			enc.Encode(sdk.Message{JSONRPC: "2.0", ID: msg.ID})
+			return
+		}
+	}
+}
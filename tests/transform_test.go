@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"testing"
+	"time"
+)
+
+func TestTransform(t *testing.T) {
+	records := []any{
+		map[string]any{"code": "7", "price": "12.5", "active": "yes", "day": "2024-03-01", "internal": "x", "qty": 3},
+	}
+
+	result := runStep(t, "transform", map[string]any{
+		"list":   "ctx.input1",
+		"rename": map[string]any{"code": "id"},
+		"fields": []any{
+			map[string]any{"name": "id", "type": "int"},
+			map[string]any{"name": "price", "type": "decimal", "scale": 2},
+			map[string]any{"name": "active", "type": "bool"},
+			map[string]any{"name": "day", "type": "date", "layout": "2006-01-02"},
+			map[string]any{"name": "total", "value": "record.qty * parseFloat(record.price)", "type": "float"},
+		},
+		"drop": []any{"internal"},
+	}, records)
+
+	record := result["default"].Value.([]any)[0].(map[string]any)
+	expected := map[string]any{
+		"id":     int64(7),
+		"price":  "12.50",
+		"active": true,
+		"day":    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"total":  37.5,
+		"qty":    3,
+	}
+	if len(record) != len(expected) {
+		t.Errorf("Expected fields %v, got %v", expected, record)
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Field %s: expected %v (%T), got %v (%T)", key, value, value, record[key], record[key])
+		}
+	}
+}
+
+func TestTransformKeep(t *testing.T) {
+	result := runStep(t, "transform", map[string]any{
+		"list": "ctx.input1",
+		"keep": []any{"a"},
+	}, []any{map[string]any{"a": 1, "b": 2}})
+
+	record := result["default"].Value.([]any)[0].(map[string]any)
+	if len(record) != 1 || record["a"] != 1 {
+		t.Errorf("Expected only field a, got %v", record)
+	}
+}
+
+func TestMapTypeCoercion(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("map")
+	stepInstance, err := stepFactory("testMapTypes", map[string]any{
+		"fields": []any{
+			map[string]any{"name": "count", "value": "ctx.input1.count", "type": "int"},
+			map[string]any{"name": "enabled", "value": "ctx.input1.enabled", "type": "bool"},
+			map[string]any{"name": "label", "value": "ctx.input1.count", "type": "string"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+
+	state := &core.PipelineState{
+		Results: map[string]map[string]*core.Data{
+			"input1": core.CreateDefaultResultData(map[string]any{"count": "42", "enabled": "true"}),
+		},
+	}
+	result, err := stepInstance.Run(context.Background(), state)
+	if err != nil {
+		t.Fatalf("Step execution failed: %v", err)
+	}
+
+	fields := result["default"].Value.(map[string]any)
+	if fields["count"] != int64(42) || fields["enabled"] != true || fields["label"] != "42" {
+		t.Errorf("Unexpected coerced fields %v", fields)
+	}
+}
+
+func TestFlattenAndNest(t *testing.T) {
+	orders := []any{
+		map[string]any{
+			"id":       1,
+			"customer": map[string]any{"name": "Alice", "address": map[string]any{"city": "Rome"}},
+			"items": []any{
+				map[string]any{"sku": "a", "qty": 2},
+				map[string]any{"sku": "b", "qty": 1},
+			},
+			"tags": []any{"x", "y"},
+		},
+		map[string]any{
+			"id":       2,
+			"customer": map[string]any{"name": "Bob", "address": map[string]any{"city": "Milan"}},
+			"items":    []any{},
+			"tags":     []any{},
+		},
+	}
+
+	result := runStep(t, "flatten", map[string]any{"list": "ctx.input1"}, orders)
+	rows := result["default"].Value.([]any)
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %v", rows)
+	}
+	first := rows[0].(map[string]any)
+	if first["id"] != 1 || first["customer.address.city"] != "Rome" || first["items.sku"] != "a" || first["items.qty"] != 2 {
+		t.Errorf("Unexpected flattened row %v", first)
+	}
+	if last := rows[2].(map[string]any); last["id"] != 2 || last["customer.name"] != "Bob" {
+		t.Errorf("Expected parent row for order without items, got %v", last)
+	}
+
+	result = runStep(t, "nest", map[string]any{
+		"list":         "ctx.input1",
+		"key":          []any{"id"},
+		"children":     "items",
+		"child_prefix": "items.",
+	}, rows)
+	nested := result["default"].Value.([]any)
+	if len(nested) != 2 {
+		t.Fatalf("Expected 2 parents, got %v", nested)
+	}
+	parent := nested[0].(map[string]any)
+	items := parent["items"].([]any)
+	if len(items) != 2 || items[1].(map[string]any)["sku"] != "b" {
+		t.Errorf("Unexpected children %v", items)
+	}
+	if parent["customer"].(map[string]any)["address"].(map[string]any)["city"] != "Rome" {
+		t.Errorf("Expected nested customer, got %v", parent)
+	}
+	if items := nested[1].(map[string]any)["items"].([]any); len(items) != 0 {
+		t.Errorf("Expected no children for order 2, got %v", items)
+	}
+}
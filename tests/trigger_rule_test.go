@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runPipeline runs the steps and returns the last event of every step.
+// It fails the test if the run does not finish.
+func runPipeline(t *testing.T, steps []pipeline.StepConfig) (map[string]core.ChangeEventType, error) {
+	t.Helper()
+	pl, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: steps})
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	var mu sync.Mutex
+	events := make(map[string]core.ChangeEventType)
+	pl.OnChange = func(event core.ChangeEvent) {
+		mu.Lock()
+		events[event.StepName] = event.Type
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- pl.Run(ctx, slog.Default()) }()
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		t.Fatalf("Pipeline did not finish, some steps are blocked")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return maps.Clone(events), err
+}
+
+func script(name, source string, inputs ...string) pipeline.StepConfig {
+	return pipeline.StepConfig{Name: name, Type: "script", Inputs: inputs, Config: map[string]any{"script": source}}
+}
+
+func TestSkipCascadesFromUntakenBranch(t *testing.T) {
+	events, err := runPipeline(t, []pipeline.StepConfig{
+		{Name: "check", Type: "if", Config: map[string]any{"condition": "1 > 2"}},
+		script("yes", "'yes'", "check:true"),
+		script("after_yes", "ctx.yes", "yes"),
+		script("no", "'no'", "check:false"),
+	})
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	expected := map[string]core.ChangeEventType{
+		"check":     core.ChangeEventTypeEnd,
+		"yes":       core.ChangeEventTypeSkip,
+		"after_yes": core.ChangeEventTypeSkip,
+		"no":        core.ChangeEventTypeEnd,
+	}
+	if !maps.Equal(events, expected) {
+		t.Errorf("Expected step outcomes %v, got %v", expected, events)
+	}
+}
+
+func TestTriggerRuleAnySuccessMergesBranches(t *testing.T) {
+	merge := script("merge", "ctx.yes ?? ctx.no", "yes", "no")
+	merge.TriggerRule = pipeline.TriggerAnySuccess
+
+	pl, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: []pipeline.StepConfig{
+		{Name: "check", Type: "if", Config: map[string]any{"condition": "true"}},
+		script("yes", "'yes'", "check:true"),
+		script("no", "'no'", "check:false"),
+		merge,
+	}})
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	var merged any
+	pl.OnChange = func(event core.ChangeEvent) {
+		if event.Type == core.ChangeEventTypeEnd && event.StepName == "merge" {
+			merged = event.Data["default"].Value
+		}
+	}
+	if err := pl.Run(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if merged != "yes" {
+		t.Errorf("Expected merge to run with the taken branch, got %v", merged)
+	}
+}
+
+func TestTriggerRuleAllDoneRunsAfterFailure(t *testing.T) {
+	cleanup := script("cleanup", "'done'", "load")
+	cleanup.TriggerRule = pipeline.TriggerAllDone
+
+	events, err := runPipeline(t, []pipeline.StepConfig{
+		script("load", "throw new Error('boom')"),
+		script("report", "ctx.load", "load"),
+		cleanup,
+	})
+	if err == nil {
+		t.Errorf("Expected the run to report the failed step")
+	}
+
+	expected := map[string]core.ChangeEventType{
+		"load":    core.ChangeEventTypeError,
+		"report":  core.ChangeEventTypeSkip,
+		"cleanup": core.ChangeEventTypeEnd,
+	}
+	if !maps.Equal(events, expected) {
+		t.Errorf("Expected step outcomes %v, got %v", expected, events)
+	}
+}
+
+func TestUnknownTriggerRule(t *testing.T) {
+	step := script("a", "1")
+	step.TriggerRule = "sometimes"
+	if _, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: []pipeline.StepConfig{step}}); err == nil {
+		t.Errorf("Expected an error for an unknown trigger rule")
+	}
+}
+
+func TestUnknownInput(t *testing.T) {
+	_, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: []pipeline.StepConfig{
+		script("a", "1"),
+		script("b", "ctx.a", "a:missing"),
+		script("c", "ctx.b", "bb"),
+	}})
+	if err == nil || err.Error() != "step c: unknown input bb" {
+		t.Errorf("Expected an unknown input error, got %v", err)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	_, err := pipeline.LoadPipeline(pipeline.PipelineConfig{Steps: []pipeline.StepConfig{
+		script("a", "1"),
+		script("b", "ctx.a", "a", "d"),
+		script("c", "ctx.b", "b"),
+		script("d", "ctx.c", "c"),
+	}})
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
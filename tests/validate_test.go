@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var validateRecords = []any{
+	map[string]any{"id": 1, "email": "a@example.com", "status": "active", "total": 10},
+	map[string]any{"id": 2.5, "email": "not-an-email", "status": "active", "total": 5},
+	map[string]any{"email": "c@example.com", "status": "deleted", "total": -1},
+}
+
+func TestValidateSchema(t *testing.T) {
+	result := runStep(t, "validate", map[string]any{
+		"list": "ctx.input1",
+		"schema": map[string]any{
+			"id":     map[string]any{"type": "int", "required": true, "min": 1},
+			"email":  map[string]any{"type": "string", "pattern": "^[^@]+@[^@]+$"},
+			"status": map[string]any{"enum": []any{"active", "inactive"}},
+		},
+		"rules": []any{
+			map[string]any{"name": "positive_total", "condition": "record.total >= 0", "message": "total must not be negative"},
+		},
+	}, validateRecords)
+
+	if ids := fieldValues(result["valid"].Value, "id"); !reflect.DeepEqual(ids, []any{1}) {
+		t.Errorf("Expected valid ids [1], got %v", ids)
+	}
+
+	invalid := result["invalid"].Value.([]any)
+	if len(invalid) != 2 {
+		t.Fatalf("Expected 2 invalid records, got %v", invalid)
+	}
+	if errs := invalid[0].(map[string]any)["_errors"].([]any); len(errs) != 2 {
+		t.Errorf("Expected type and pattern violations, got %v", errs)
+	}
+	if errs := invalid[1].(map[string]any)["_errors"].([]any); len(errs) != 3 {
+		t.Errorf("Expected required, enum and rule violations, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	schema := `{
+		"type": "object",
+		"required": ["id", "email"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"email": {"type": "string", "maxLength": 13},
+			"status": {"type": ["string", "null"]}
+		}
+	}`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runStep(t, "validate", map[string]any{
+		"list":         "ctx.input1",
+		"schema_file":  schemaPath,
+		"errors_field": "violations",
+	}, validateRecords)
+
+	if ids := fieldValues(result["valid"].Value, "id"); !reflect.DeepEqual(ids, []any{1}) {
+		t.Errorf("Expected valid ids [1], got %v", ids)
+	}
+	invalid := result["invalid"].Value.([]any)
+	if len(invalid) != 2 || invalid[1].(map[string]any)["violations"] == nil {
+		t.Errorf("Expected 2 invalid records with violations, got %v", invalid)
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"go-etl/core"
+	"go-etl/pipeline"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func execSql(t *testing.T, dbPath, query string) {
+	stepFactory, _ := pipeline.GetStepFactory("sqlite")
+	step, err := stepFactory("exec", map[string]any{"connection": dbPath, "query": query})
+	if err != nil {
+		t.Fatalf("Failed to create step instance: %v", err)
+	}
+	if _, err := step.Run(context.Background(), nil); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+}
+
+func runIncremental(t *testing.T, dir string, extraSteps ...pipeline.StepConfig) (int, error) {
+	steps := append([]pipeline.StepConfig{
+		{
+			Name: "extract",
+			Type: "sqlite",
+			Config: map[string]any{
+				"connection": filepath.Join(dir, "source.db"),
+				"query":      "SELECT * FROM orders WHERE id > ? ORDER BY id",
+				"params":     []any{"state.watermark.orders"},
+			},
+			Watermark: &pipeline.WatermarkConfig{Name: "orders", Column: "id", Initial: 0},
+		},
+	}, extraSteps...)
+
+	pl, err := pipeline.LoadPipeline(pipeline.PipelineConfig{
+		State: &pipeline.StateConfig{Path: filepath.Join(dir, "state.db")},
+		Steps: steps,
+	})
+	if err != nil {
+		t.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	extracted := -1
+	pl.OnChange = func(event core.ChangeEvent) {
+		if event.Type == core.ChangeEventTypeEnd && event.StepName == "extract" {
+			extracted = len(event.Data["default"].Value.([]map[string]any))
+		}
+	}
+	err = pl.Run(context.Background(), slog.Default())
+	return extracted, err
+}
+
+func TestWatermarkIncrementalLoad(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.db")
+	execSql(t, source, "CREATE TABLE orders (id INTEGER PRIMARY KEY, total REAL)")
+	execSql(t, source, "INSERT INTO orders VALUES (1, 10), (2, 20), (3, 30)")
+
+	if n, err := runIncremental(t, dir); err != nil || n != 3 {
+		t.Fatalf("Expected first run to extract 3 rows, got %d (%v)", n, err)
+	}
+	if n, err := runIncremental(t, dir); err != nil || n != 0 {
+		t.Fatalf("Expected second run to extract 0 rows, got %d (%v)", n, err)
+	}
+
+	execSql(t, source, "INSERT INTO orders VALUES (4, 40)")
+	failing := pipeline.StepConfig{
+		Name:   "fail",
+		Type:   "sqlite",
+		Config: map[string]any{"connection": source, "query": "SELECT * FROM missing_table"},
+	}
+	if n, err := runIncremental(t, dir, failing); err == nil || n != 1 {
+		t.Fatalf("Expected failed run extracting 1 row, got %d (%v)", n, err)
+	}
+
+	// The failed run must not have advanced the watermark
+	if n, err := runIncremental(t, dir); err != nil || n != 1 {
+		t.Fatalf("Expected row 4 to be extracted again, got %d (%v)", n, err)
+	}
+}
+
+// gateStep signals when it is reached and waits to be released.
+type gateStep struct {
+	reached chan struct{}
+	release chan struct{}
+}
+
+func (g *gateStep) Name() string { return "gate" }
+
+func (g *gateStep) Run(ctx context.Context, state *core.PipelineState) (map[string]*core.Data, error) {
+	close(g.reached)
+	<-g.release
+	return core.CreateDefaultResultData(nil), nil
+}
+
+func TestWatermarkOverlappingRuns(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.db")
+	execSql(t, source, "CREATE TABLE orders (id INTEGER PRIMARY KEY, total REAL)")
+	execSql(t, source, "INSERT INTO orders VALUES (1, 10), (2, 20), (3, 30)")
+
+	gate := &gateStep{reached: make(chan struct{}), release: make(chan struct{})}
+	pipeline.RegisterStepType("test_gate", func(name string, config map[string]any) (core.Step, error) {
+		return gate, nil
+	})
+
+	// The first run extracts up to 3 and finishes after a second run extracting up to 5
+	first := make(chan error, 1)
+	go func() {
+		_, err := runIncremental(t, dir, pipeline.StepConfig{Name: "gate", Type: "test_gate", Inputs: []string{"extract"}})
+		first <- err
+	}()
+	<-gate.reached
+	execSql(t, source, "INSERT INTO orders VALUES (4, 40), (5, 50)")
+	if n, err := runIncremental(t, dir); err != nil || n != 5 {
+		t.Fatalf("Expected the second run to extract 5 rows, got %d (%v)", n, err)
+	}
+	close(gate.release)
+	if err := <-first; err != nil {
+		t.Fatalf("First run failed: %v", err)
+	}
+
+	// The watermark must not move back to 3
+	if n, err := runIncremental(t, dir); err != nil || n != 0 {
+		t.Fatalf("Expected the next run to extract 0 rows, got %d (%v)", n, err)
+	}
+}
+
+func TestWatermarkAdvancesAfterFailedItem(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.db")
+	execSql(t, source, "CREATE TABLE orders (id INTEGER PRIMARY KEY, total REAL)")
+	execSql(t, source, "INSERT INTO orders VALUES (1, 10), (2, 20), (3, 30)")
+
+	// A failing item of a foreach tolerating errors must not drop the
+	// watermark observed by the parent run
+	items := pipeline.StepConfig{
+		Name:   "items",
+		Type:   "foreach",
+		Inputs: []string{"extract"},
+		Config: map[string]any{
+			"list":              "ctx.extract",
+			"continue_on_error": true,
+			"steps": []any{
+				map[string]any{"name": "check", "type": "script", "config": map[string]any{
+					"script": "if (ctx.foreach.item.id == 2) { throw new Error('bad order'); } ctx.foreach.item.id",
+				}},
+			},
+		},
+	}
+	if n, err := runIncremental(t, dir, items); err != nil || n != 3 {
+		t.Fatalf("Expected first run to extract 3 rows, got %d (%v)", n, err)
+	}
+	if n, err := runIncremental(t, dir); err != nil || n != 0 {
+		t.Fatalf("Expected second run to extract 0 rows, got %d (%v)", n, err)
+	}
+}
+
+func TestSqliteInvalidParams(t *testing.T) {
+	stepFactory, _ := pipeline.GetStepFactory("sqlite")
+	_, err := stepFactory("extract", map[string]any{
+		"connection": "source.db",
+		"query":      "SELECT * FROM orders WHERE id > ?",
+		"params":     map[string]any{"id": "state.watermark.orders"},
+	})
+	if err == nil {
+		t.Error("Expected an error for params that are not a list")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go-etl/core"
 	"go-etl/pipeline"
+	"go-etl/steps"
 	"log/slog"
 	"net/http"
 
@@ -45,6 +46,7 @@ func StartServer(logger *slog.Logger) {
 	server.Mux().HandleFunc("/ws", handleConnections)
 	server.Mux().HandleFunc("/start", handleStart(logger))
 	server.Mux().HandleFunc("/upload", handleUpload(logger))
+	server.Mux().HandleFunc("/plugins", handlePlugins)
 	server.Mux().Handle("/", http.FileServer(http.Dir("./web/static")))
 
 	go startWebSocket()
@@ -68,6 +70,14 @@ func logToClients(path string, msg string) {
 	broadcast <- message
 }
 
+// handlePlugins lists the plugins installed in the plugin search path.
+func handlePlugins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(steps.InstalledPlugins()); err != nil {
+		http.Error(w, "JSON error", 500)
+	}
+}
+
 func handleUpload(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		file, _, err := r.FormFile("file")
@@ -130,7 +140,8 @@ func handleStart(logger *slog.Logger) http.HandlerFunc {
 			}
 
 			if err := pl.Run(context.Background(), logger); err != nil {
-				panic(err)
+				logger.Error("Pipeline run failed", "error", err)
+				logToClients("status", "Pipeline failed: "+err.Error())
 			}
 
 		}()